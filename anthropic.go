@@ -0,0 +1,283 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultAnthropicURL       = "https://api.anthropic.com/v1/messages"
+	anthropicVersion          = "2023-06-01"
+	defaultAnthropicMaxTokens = 4096
+)
+
+// anthropicProvider talks to the Claude Messages API. Unlike the OpenAI shape
+// the system prompt is a top-level field, max_tokens is mandatory, images are
+// typed source blocks and the reply is an array of content blocks.
+type anthropicProvider struct {
+	model    string
+	key      string
+	endpoint string
+	client   *http.Client
+	header   http.Header
+	cacheTTL string
+	chatOptions
+}
+
+type anthropicPayload struct {
+	Model       string                   `json:"model"`
+	System      interface{}              `json:"system,omitempty"`
+	Messages    []map[string]interface{} `json:"messages"`
+	MaxTokens   int                      `json:"max_tokens"`
+	Temperature *float64                 `json:"temperature,omitempty"`
+	TopP        *float64                 `json:"top_p,omitempty"`
+	Stop        []string                 `json:"stop_sequences,omitempty"`
+	Stream      bool                     `json:"stream,omitempty"`
+}
+
+func (p *anthropicProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	respBody, err := postJSON(ctx, p.client, p.endpoint, p.payload(history, images, systemPrompt, false), "", p.requestHeader())
+	if err != nil {
+		return nil, err
+	}
+	return parseAnthropicResponse(respBody)
+}
+
+func (p *anthropicProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
+	body, err := openJSONStream(ctx, p.client, p.endpoint, p.payload(history, images, systemPrompt, true), "", p.requestHeader())
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return parseAnthropicStream(ctx, body, callback)
+}
+
+func (p *anthropicProvider) requestHeader() http.Header {
+	header := p.header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("x-api-key", p.key)
+	header.Set("anthropic-version", anthropicVersion)
+	return header
+}
+
+func (p *anthropicProvider) payload(history []Message, images []string, systemPrompt string, stream bool) *anthropicPayload {
+	if p.jsonSchema != nil {
+		systemPrompt = appendSchemaInstruction(systemPrompt, p.jsonSchema)
+	}
+	system, messages := anthropicMessages(history, images, systemPrompt)
+	maxTokens := defaultAnthropicMaxTokens
+	if p.maxTokens != nil {
+		maxTokens = *p.maxTokens
+	}
+	payload := &anthropicPayload{
+		Model:       p.model,
+		Messages:    messages,
+		MaxTokens:   maxTokens,
+		Temperature: p.temperature,
+		TopP:        p.topP,
+		Stop:        p.stop,
+		Stream:      stream,
+	}
+	if system != "" {
+		payload.System = system
+	}
+	if p.cacheTTL != "" {
+		p.markCacheBreakpoint(payload)
+	}
+	return payload
+}
+
+// markCacheBreakpoint caches the prompt prefix: the system prompt when there
+// is one, otherwise everything up to the last message.
+func (p *anthropicProvider) markCacheBreakpoint(payload *anthropicPayload) {
+	cacheControl := map[string]interface{}{"type": "ephemeral", "ttl": p.cacheTTL}
+	if system, ok := payload.System.(string); ok {
+		payload.System = []map[string]interface{}{{"type": "text", "text": system, "cache_control": cacheControl}}
+		return
+	}
+	if len(payload.Messages) == 0 {
+		return
+	}
+	blocks, _ := payload.Messages[len(payload.Messages)-1]["content"].([]map[string]interface{})
+	if len(blocks) > 0 {
+		blocks[len(blocks)-1]["cache_control"] = cacheControl
+	}
+}
+
+// anthropicMessages moves system messages into the top-level system prompt,
+// turns assistant tool calls into tool_use blocks and sends tool results as
+// user turns with a tool_result block.
+func anthropicMessages(history []Message, images []string, systemPrompt string) (string, []map[string]interface{}) {
+	system := []string{}
+	if systemPrompt != "" {
+		system = append(system, systemPrompt)
+	}
+	msgs := make([]map[string]interface{}, 0, len(history))
+	for i, m := range history {
+		if m.Role == "system" {
+			system = append(system, messageText(m))
+			continue
+		}
+
+		var blocks []map[string]interface{}
+		if len(m.ContentParts) > 0 {
+			blocks = anthropicBlocks(m.ContentParts)
+		} else if m.Content != "" {
+			blocks = []map[string]interface{}{{"type": "text", "text": m.Content}}
+		}
+		if i == len(history)-1 && m.Role == "user" {
+			for _, img := range images {
+				blocks = append(blocks, imagePart{url: img}.anthropic())
+			}
+		}
+		for _, call := range m.ToolCalls {
+			blocks = append(blocks, anthropicToolUse(call))
+		}
+
+		role := m.Role
+		if role == "tool" {
+			role = "user"
+			blocks = []map[string]interface{}{{"type": "tool_result", "tool_use_id": m.ToolCallID, "content": blocks}}
+		}
+		msgs = append(msgs, map[string]interface{}{"role": role, "content": blocks})
+	}
+	return strings.Join(system, "\n\n"), msgs
+}
+
+// anthropicToolUse converts an OpenAI tool call, whose arguments are a JSON
+// string, into a tool_use block, whose input is the decoded object.
+func anthropicToolUse(call ToolCall) map[string]interface{} {
+	input := json.RawMessage(call.Function.Arguments)
+	if !json.Valid(input) {
+		input = json.RawMessage("{}")
+	}
+	return map[string]interface{}{"type": "tool_use", "id": call.ID, "name": call.Function.Name, "input": input}
+}
+
+func anthropicBlocks(parts []ContentPart) []map[string]interface{} {
+	blocks := make([]map[string]interface{}, 0, len(parts))
+	for _, p := range parts {
+		switch {
+		case p.Type == "text":
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": p.Text})
+		case p.Type == "image_url" && p.ImageURL != nil:
+			blocks = append(blocks, newImagePart(p.ImageURL).anthropic())
+		}
+	}
+	return blocks
+}
+
+func parseAnthropicResponse(body []byte) (*Response, error) {
+	var r struct {
+		Model   string `json:"model"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string          `json:"stop_reason"`
+		Usage      *anthropicUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil || len(r.Content) == 0 {
+		return parseResponse(body)
+	}
+
+	var text strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	resp := &Response{Content: text.String(), Model: r.Model, FinishReason: r.StopReason, Raw: body}
+	if r.Usage != nil {
+		resp.Usage = r.Usage.tokenUsage()
+	}
+	return resp, nil
+}
+
+type anthropicUsage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+func (u *anthropicUsage) tokenUsage() *TokenUsage {
+	return &TokenUsage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+// parseAnthropicStream reads the Messages API event stream. Every data line
+// repeats its event name in "type", so the event: lines are not needed.
+func parseAnthropicStream(ctx context.Context, reader io.Reader, callback StreamCallback) error {
+	var (
+		model string
+		usage *anthropicUsage
+	)
+	scanner := newStreamScanner(ctx, reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Model string          `json:"model"`
+				Usage *anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Usage *anthropicUsage `json:"usage"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "message_start":
+			model = event.Message.Model
+			usage = event.Message.Usage
+		case "message_delta":
+			// The final output count; input_tokens is only repeated by
+			// newer API versions.
+			if event.Usage != nil {
+				if usage == nil {
+					usage = &anthropicUsage{}
+				}
+				if event.Usage.InputTokens > 0 {
+					usage.InputTokens = event.Usage.InputTokens
+				}
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				if err := callback(StreamChunk{Content: event.Delta.Text, Model: model}); err != nil {
+					return err
+				}
+			}
+		case "message_stop":
+			done := StreamChunk{Model: model, Done: true}
+			if usage != nil {
+				done.Usage = usage.tokenUsage()
+			}
+			return callback(done)
+		case "error":
+			return errors.New(event.Error.Message)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,71 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAnthropicPromptCacheTTL(t *testing.T) {
+	var (
+		payload         map[string]interface{}
+		apiKey, version string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey, version = r.Header.Get("x-api-key"), r.Header.Get("anthropic-version")
+		body, _ := io.ReadAll(r.Body)
+		payload = decodeBody(t, body)
+		io.WriteString(w, `{"model":"claude-3-5-haiku","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+	}))
+	defer srv.Close()
+
+	cache := func(ttl string) map[string]interface{} {
+		return map[string]interface{}{"type": "ephemeral", "ttl": ttl}
+	}
+	tests := []struct {
+		name         string
+		ttl          string
+		systemPrompt string
+		wantSystem   interface{}
+		wantLast     map[string]interface{}
+	}{
+		{"system prompt cached", "1h", "You are a librarian.",
+			[]interface{}{map[string]interface{}{"type": "text", "text": "You are a librarian.", "cache_control": cache("1h")}},
+			map[string]interface{}{"type": "text", "text": "Find the book."}},
+		{"last message cached without a system prompt", "5m", "",
+			nil,
+			map[string]interface{}{"type": "text", "text": "Find the book.", "cache_control": cache("5m")}},
+		{"no ttl", "", "You are a librarian.",
+			"You are a librarian.",
+			map[string]interface{}{"type": "text", "text": "Find the book."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Provider: "anthropic", Endpoint: srv.URL + "/v1/messages", Model: "claude-3-5-haiku", APIKey: "sk-ant", SystemPrompt: tt.systemPrompt, Prompt: "Find the book."}
+			if tt.ttl != "" {
+				WithPromptCacheTTL(tt.ttl)(req)
+			}
+			resp, err := NewClient().Send(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if resp.Content != "ok" {
+				t.Errorf("content = %q", resp.Content)
+			}
+			if apiKey != "sk-ant" || version != anthropicVersion {
+				t.Errorf("x-api-key = %q, anthropic-version = %q", apiKey, version)
+			}
+			if got := payload["system"]; !reflect.DeepEqual(got, tt.wantSystem) {
+				t.Errorf("system = %v, want %v", got, tt.wantSystem)
+			}
+			msgs := sentMessages(payload)
+			blocks, _ := msgs[len(msgs)-1]["content"].([]interface{})
+			if len(blocks) == 0 || !reflect.DeepEqual(blocks[len(blocks)-1], tt.wantLast) {
+				t.Errorf("last block = %v, want %v", blocks, tt.wantLast)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package llmclient
+
+import (
+	"strings"
+	"sync"
+)
+
+// keyRing hands out a provider's API keys round-robin: every request starts
+// one key further than the previous one.
+type keyRing struct {
+	mu   sync.Mutex
+	keys []string
+	next int
+}
+
+// WithAPIKeys spreads requests for provider across several keys. Requests
+// that set APIKey themselves are left alone. When a key answers 401 (revoked
+// or invalid) or 429 the same request is retried with the next key.
+func WithAPIKeys(provider string, keys []string) ClientOption {
+	return func(c *Client) {
+		if c.apiKeys == nil {
+			c.apiKeys = make(map[string]*keyRing)
+		}
+		c.apiKeys[strings.ToLower(strings.TrimSpace(provider))] = &keyRing{keys: append([]string(nil), keys...)}
+	}
+}
+
+// requestKeys returns the keys to try for req, in order.
+func (c *Client) requestKeys(req *Request) []string {
+	ring := c.apiKeys[strings.ToLower(strings.TrimSpace(req.Provider))]
+	if req.APIKey != "" || ring == nil || len(ring.keys) == 0 {
+		return []string{req.APIKey}
+	}
+	ring.mu.Lock()
+	start := ring.next
+	ring.next = (ring.next + 1) % len(ring.keys)
+	ring.mu.Unlock()
+
+	keys := make([]string, 0, len(ring.keys))
+	keys = append(keys, ring.keys[start:]...)
+	return append(keys, ring.keys[:start]...)
+}
+
+// isKeyRejected reports whether err means this key cannot be used right now.
+func isKeyRejected(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && (apiErr.StatusCode == 401 || apiErr.StatusCode == 429)
+}
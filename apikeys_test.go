@@ -0,0 +1,101 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// keyServer answers chat requests and records the bearer key of each one;
+// requests made with a key listed in reject get that key's status.
+func keyServer(t *testing.T, reject map[string]int) (*httptest.Server, func() []string) {
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		mu.Lock()
+		keys = append(keys, key)
+		mu.Unlock()
+		if status := reject[key]; status != 0 {
+			w.WriteHeader(status)
+			io.WriteString(w, `{"error":"rejected"}`)
+			return
+		}
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+deltaEvent("ok")+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), keys...)
+	}
+}
+
+func TestAPIKeysRoundRobin(t *testing.T) {
+	srv, sentKeys := keyServer(t, nil)
+	c := NewClient(WithAPIKeys("OpenRouter", []string{"a", "b", "c"}))
+	for i := 0; i < 4; i++ {
+		if _, err := c.Send(context.Background(), &Request{Provider: "openrouter", Endpoint: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}); err != nil {
+			t.Fatalf("Send %d: %v", i, err)
+		}
+	}
+	if _, err := c.Send(context.Background(), &Request{Provider: "openrouter", Endpoint: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi", APIKey: "own"}); err != nil {
+		t.Fatalf("Send with APIKey: %v", err)
+	}
+	if got, want := sentKeys(), []string{"a", "b", "c", "a", "own"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %q, want %q", got, want)
+	}
+}
+
+func TestAPIKeysSkipRejectedKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   []string
+		ok     bool
+	}{
+		{"401 tries the next key", http.StatusUnauthorized, []string{"a", "b"}, true},
+		{"429 tries the next key", http.StatusTooManyRequests, []string{"a", "b"}, true},
+		{"500 is returned", http.StatusInternalServerError, []string{"a"}, false},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				srv, sentKeys := keyServer(t, map[string]int{"a": tt.status})
+				c := NewClient(WithAPIKeys("openrouter", []string{"a", "b"}))
+				req := &Request{Provider: "openrouter", Endpoint: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}
+				var err error
+				if stream {
+					_, _, err = collectStream(t, c, req)
+				} else {
+					_, err = c.Send(context.Background(), req)
+				}
+				if tt.ok && err != nil {
+					t.Fatalf("request: %v", err)
+				}
+				if apiErr, ok := AsAPIError(err); !tt.ok && (!ok || apiErr.StatusCode != tt.status) {
+					t.Errorf("err = %v, want the %d APIError", err, tt.status)
+				}
+				if got := sentKeys(); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("keys = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
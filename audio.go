@@ -15,28 +15,38 @@ type AudioRequest struct {
 	Model    string
 	APIKey   string
 	Prompt   string
+	Voice    string
 }
 
 type AudioResponse struct {
-	Data []byte
+	Data        []byte
+	ContentType string
+	Headers     map[string]string
 }
 
 func (c *Client) GenerateAudio(ctx context.Context, req *AudioRequest) (*AudioResponse, error) {
 	if req == nil {
 		return nil, errors.New("audio request is nil")
 	}
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	provider, err := c.newAudioProvider(req)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := provider.Generate(ctx, req)
+	data, contentType, err := provider.Generate(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &AudioResponse{Data: data}, nil
+	c.observeResponseBytes(req.Provider, len(data))
+	return &AudioResponse{Data: data, ContentType: contentType, Headers: captured.headers()}, nil
 }
 
 func (c *Client) newAudioProvider(req *AudioRequest) (audioProvider, error) {
@@ -51,14 +61,14 @@ func (c *Client) newAudioProvider(req *AudioRequest) (audioProvider, error) {
 }
 
 type audioProvider interface {
-	Generate(ctx context.Context, req *AudioRequest) ([]byte, error)
+	Generate(ctx context.Context, req *AudioRequest) ([]byte, string, error)
 }
 
 type pollinationsAudioProvider struct {
 	client *http.Client
 }
 
-func (p *pollinationsAudioProvider) Generate(ctx context.Context, req *AudioRequest) ([]byte, error) {
+func (p *pollinationsAudioProvider) Generate(ctx context.Context, req *AudioRequest) ([]byte, string, error) {
 	encodedPrompt := url.PathEscape(req.Prompt)
 	endpoint := fmt.Sprintf("https://gen.pollinations.ai/audio/%s", encodedPrompt)
 
@@ -66,6 +76,9 @@ func (p *pollinationsAudioProvider) Generate(ctx context.Context, req *AudioRequ
 	if req.Model != "" {
 		params.Set("model", req.Model)
 	}
+	if req.Voice != "" {
+		params.Set("voice", req.Voice)
+	}
 
 	if len(params) > 0 {
 		endpoint = endpoint + "?" + params.Encode()
@@ -73,7 +86,7 @@ func (p *pollinationsAudioProvider) Generate(ctx context.Context, req *AudioRequ
 
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, "", fmt.Errorf("create request: %w", err)
 	}
 
 	if req.APIKey != "" {
@@ -82,18 +95,21 @@ func (p *pollinationsAudioProvider) Generate(ctx context.Context, req *AudioRequ
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("request: %w", err)
+		return nil, "", fmt.Errorf("request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
+		return nil, "", fmt.Errorf("read response: %w", err)
 	}
 
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(data))
+		return nil, "", &APIError{StatusCode: resp.StatusCode, Body: string(data), Provider: req.Provider}
+	}
+	if err := checkContentType(resp, data); err != nil {
+		return nil, "", err
 	}
 
-	return data, nil
+	return data, resp.Header.Get("Content-Type"), nil
 }
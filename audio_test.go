@@ -0,0 +1,59 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestGenerateAudio(t *testing.T) {
+	tests := []struct {
+		name        string
+		voice       string
+		contentType string
+		body        string
+		wantErr     error
+	}{
+		{"voice sent", "nova", "audio/mpeg", "ID3fake-mp3", nil},
+		{"default voice", "", "audio/wav", "RIFFfake-wav", nil},
+		{"HTML error page", "nova", "text/html; charset=utf-8", "<html>Service Unavailable</html>", ErrUnexpectedContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			var query url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, query = r.URL.Path, r.URL.Query()
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			req := &AudioRequest{Provider: "pollinations", Model: "openai-audio", Prompt: "hello there"}
+			WithAudioVoice(tt.voice)(req)
+			resp, err := NewClient(WithHTTPClient(rewriteClient(srv))).GenerateAudio(context.Background(), req)
+			if path != "/audio/hello there" || query.Get("model") != "openai-audio" {
+				t.Errorf("requested %q with %v", path, query)
+			}
+			if voice, ok := query["voice"]; (tt.voice == "" && ok) || (tt.voice != "" && (!ok || voice[0] != tt.voice)) {
+				t.Errorf("voice = %v, want %q", voice, tt.voice)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateAudio: %v", err)
+			}
+			if string(resp.Data) != tt.body || resp.ContentType != tt.contentType {
+				t.Errorf("audio = %q as %q, want %q as %q", resp.Data, resp.ContentType, tt.body, tt.contentType)
+			}
+		})
+	}
+}
@@ -13,11 +13,13 @@ type Balance struct {
 	Credits  float64        `json:"credits,omitempty"`
 	Balance  float64        `json:"balance,omitempty"`
 	Currency string         `json:"currency,omitempty"`
+	Tier     string         `json:"tier,omitempty"`
 	Raw      map[string]any `json:"-"`
 }
 
 type BalanceResponse struct {
 	Balance *Balance
+	Headers map[string]string
 	Raw     []byte
 }
 
@@ -42,6 +44,12 @@ func (c *Client) GetBalance(ctx context.Context, req *BalanceRequest) (*BalanceR
 	if req == nil {
 		return nil, fmt.Errorf("balance request is nil")
 	}
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	provider, err := c.newBalanceProvider(req)
 	if err != nil {
@@ -53,7 +61,8 @@ func (c *Client) GetBalance(ctx context.Context, req *BalanceRequest) (*BalanceR
 		return nil, err
 	}
 
-	return &BalanceResponse{Balance: bal, Raw: raw}, nil
+	c.observeResponseBytes(req.Provider, len(raw))
+	return &BalanceResponse{Balance: bal, Headers: captured.headers(), Raw: raw}, nil
 }
 
 func (c *Client) newBalanceProvider(req *BalanceRequest) (balanceProvider, error) {
@@ -96,18 +105,89 @@ func (p *pollinationsBalanceProvider) GetBalance(ctx context.Context, req *Balan
 	}
 
 	if resp.StatusCode >= 300 {
-		return nil, nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(data))
+		return nil, nil, &APIError{StatusCode: resp.StatusCode, Body: string(data), Provider: req.Provider}
+	}
+	if err := checkContentType(resp, data); err != nil {
+		return nil, nil, err
 	}
 
-	var balance Balance
-	if err := json.Unmarshal(data, &balance); err != nil {
+	balance, err := parsePollinationsBalance(data)
+	if err != nil {
 		return nil, nil, fmt.Errorf("parse response: %w", err)
 	}
+	return balance, data, nil
+}
 
-	balance.Raw = make(map[string]any)
+// parsePollinationsBalance accepts the flat {"credits": 12.5} shape as well
+// as nested ones such as {"tier": "seed", "credits": {"remaining": 12.5}},
+// with amounts given as numbers or numeric strings.
+func parsePollinationsBalance(data []byte) (*Balance, error) {
+	var r struct {
+		Credits  json.RawMessage `json:"credits"`
+		Balance  json.RawMessage `json:"balance"`
+		Currency string          `json:"currency"`
+		Tier     json.RawMessage `json:"tier"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+
+	balance := &Balance{Currency: r.Currency, Raw: make(map[string]any)}
 	_ = json.Unmarshal(data, &balance.Raw)
+	balance.Credits, _ = balanceAmount(r.Credits, &balance.Currency)
+	balance.Balance, _ = balanceAmount(r.Balance, &balance.Currency)
+	balance.Tier = balanceTier(r.Tier)
+	return balance, nil
+}
+
+var balanceAmountKeys = []string{"remaining", "balance", "available", "amount", "total"}
 
-	return &balance, data, nil
+func balanceAmount(raw json.RawMessage, currency *string) (float64, bool) {
+	if len(raw) == 0 {
+		return 0, false
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		f, err := json.Number(strings.TrimSpace(s)).Float64()
+		return f, err == nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return 0, false
+	}
+	if *currency == "" {
+		_ = json.Unmarshal(obj["currency"], currency)
+	}
+	for _, key := range balanceAmountKeys {
+		if f, ok := balanceAmount(obj[key], currency); ok {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// balanceTier reads "tier" given as a string or as {"name": ...}.
+func balanceTier(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var obj struct {
+		Name string `json:"name"`
+		Tier string `json:"tier"`
+	}
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		if obj.Name != "" {
+			return obj.Name
+		}
+		return obj.Tier
+	}
+	return ""
 }
 
 func GetBalance(provider, apiKey string) (*Balance, error) {
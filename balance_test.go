@@ -0,0 +1,57 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePollinationsBalance(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want Balance
+	}{
+		{"flat", `{"credits":12.5,"tier":"seed"}`, Balance{Credits: 12.5, Tier: "seed"}},
+		{"numeric strings", `{"credits":"12.5","balance":" 3 "}`, Balance{Credits: 12.5, Balance: 3}},
+		{"nested", `{"tier":{"name":"flower"},"credits":{"remaining":7.25,"currency":"pollen"},"balance":{"available":"1.5"}}`,
+			Balance{Credits: 7.25, Balance: 1.5, Currency: "pollen", Tier: "flower"}},
+		{"nested deeper", `{"credits":{"balance":{"amount":2}},"currency":"USD"}`, Balance{Credits: 2, Currency: "USD"}},
+		{"unknown shape", `{"credits":{"used":4}}`, Balance{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePollinationsBalance([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got.Credits != tt.want.Credits || got.Balance != tt.want.Balance || got.Currency != tt.want.Currency || got.Tier != tt.want.Tier {
+				t.Errorf("balance = %+v, want %+v", *got, tt.want)
+			}
+			if got.Raw["credits"] == nil {
+				t.Errorf("Raw = %v, want the decoded body", got.Raw)
+			}
+		})
+	}
+}
+
+func TestGetBalanceNested(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/account/balance" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("request %s with Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"tier":"seed","credits":{"remaining":"0.75","currency":"pollen"}}`)
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(WithHTTPClient(rewriteClient(srv))).GetBalance(context.Background(), &BalanceRequest{Provider: "pollinations", APIKey: "sk-test"})
+	if err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if b := resp.Balance; b.Credits != 0.75 || b.Currency != "pollen" || b.Tier != "seed" || !b.HasCredits() {
+		t.Errorf("balance = %+v", *b)
+	}
+}
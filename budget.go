@@ -0,0 +1,89 @@
+package llmclient
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"sync"
+)
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+type budgetGuard struct {
+	mu      sync.Mutex
+	limit   float64
+	spent   float64
+	pricing map[string]*ModelPricing
+	// highest holds the highest prompt and completion rates in pricing,
+	// charged for models that have none of their own.
+	highest ModelPricing
+}
+
+// WithBudgetGuard makes Send and SendStream fail with ErrBudgetExceeded once
+// the cost of the responses so far reaches limit. Models missing from models
+// are charged at the highest rates listed there, and responses without usage
+// are charged for an estimate of their prompt and reply. Streams ask for
+// usage as if WithStreamUsage were set.
+func WithBudgetGuard(limit float64, models []Model) ClientOption {
+	return func(c *Client) {
+		guard := &budgetGuard{limit: limit, pricing: make(map[string]*ModelPricing)}
+		for _, m := range models {
+			if m.Pricing == nil {
+				continue
+			}
+			guard.pricing[strings.ToLower(m.Name)] = m.Pricing
+			for _, a := range m.Aliases {
+				guard.pricing[strings.ToLower(a)] = m.Pricing
+			}
+			guard.highest.PromptTextTokens = math.Max(guard.highest.PromptTextTokens, m.Pricing.PromptTextTokens)
+			guard.highest.CompletionTextTokens = math.Max(guard.highest.CompletionTextTokens, m.Pricing.CompletionTextTokens)
+		}
+		c.budget = guard
+	}
+}
+
+func (b *budgetGuard) check() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spent >= b.limit {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+func (b *budgetGuard) record(model string, usage *TokenUsage) {
+	if b == nil || usage == nil {
+		return
+	}
+	pricing, ok := b.pricing[strings.ToLower(model)]
+	if !ok {
+		pricing = &b.highest
+	}
+	cost := float64(usage.PromptTokens)*pricing.PromptTextTokens + float64(usage.CompletionTokens)*pricing.CompletionTextTokens
+	b.mu.Lock()
+	b.spent += cost
+	b.mu.Unlock()
+}
+
+// billedUsage is the usage the budget guard charges for a response: what the
+// provider reported or, when it sent none, an estimate from the text.
+func (c *Client) billedUsage(usage *TokenUsage, systemPrompt string, history []Message, reply string) *TokenUsage {
+	if c.budget == nil || usage != nil {
+		return usage
+	}
+	prompt := int64(c.estimatePromptTokens(systemPrompt, history))
+	completion := int64(c.estimateTokens(reply))
+	return &TokenUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+func (c *Client) BudgetSpent() float64 {
+	if c.budget == nil {
+		return 0
+	}
+	c.budget.mu.Lock()
+	defer c.budget.mu.Unlock()
+	return c.budget.spent
+}
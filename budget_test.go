@@ -0,0 +1,137 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestBudgetGuardBlocksNthRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if decodeBody(t, body)["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\n")
+			io.WriteString(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":100,\"completion_tokens\":100,\"total_tokens\":200}}\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":100,"completion_tokens":100,"total_tokens":200}}`)
+	}))
+	defer srv.Close()
+
+	// Every response costs 100*0.01 + 100*0.01 = 2, so a budget of 5 allows
+	// three requests and blocks the fourth.
+	models := []Model{{Name: "m", Pricing: &ModelPricing{PromptTextTokens: 0.01, CompletionTextTokens: 0.01}}}
+	tests := []struct {
+		name   string
+		stream bool
+	}{
+		{"send", false},
+		{"stream", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(WithBudgetGuard(5, models))
+			req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi", StreamUsage: true}
+			for i := 1; i <= 4; i++ {
+				var err error
+				if tt.stream {
+					_, err = c.SendStream(context.Background(), req, func(StreamChunk) error { return nil })
+				} else {
+					_, err = c.Send(context.Background(), req)
+				}
+				if i < 4 && err != nil {
+					t.Fatalf("request %d: %v", i, err)
+				}
+				if i == 4 && !errors.Is(err, ErrBudgetExceeded) {
+					t.Fatalf("request %d: got %v, want ErrBudgetExceeded", i, err)
+				}
+			}
+			if spent := c.BudgetSpent(); spent != 6 {
+				t.Errorf("BudgetSpent() = %v, want 6", spent)
+			}
+		})
+	}
+}
+
+func TestBudgetGuardChargesUnpricedAndUnreported(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		streamUsage bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payload := decodeBody(t, body)
+		withUsage := r.URL.Path == "/usage"
+		if payload["stream"] == true {
+			opts, _ := payload["stream_options"].(map[string]interface{})
+			mu.Lock()
+			streamUsage = opts["include_usage"] == true
+			mu.Unlock()
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+deltaEvent("ok")+"\n\n")
+			if withUsage {
+				io.WriteString(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":100,\"completion_tokens\":100,\"total_tokens\":200}}\n\n")
+			}
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		if withUsage {
+			io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":100,"completion_tokens":100,"total_tokens":200}}`)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	models := []Model{
+		{Name: "cheap", Pricing: &ModelPricing{PromptTextTokens: 0.01, CompletionTextTokens: 0.02}},
+		{Name: "dear", Pricing: &ModelPricing{PromptTextTokens: 0.03, CompletionTextTokens: 0.01}},
+	}
+	// "hi" is estimated at 1 token plus 4 of message overhead, "ok" at 1.
+	tests := []struct {
+		name  string
+		path  string
+		model string
+		want  float64
+	}{
+		{"unpriced model at the highest rates", "/usage", "unknown", 100*0.03 + 100*0.02},
+		{"no usage reported", "/none", "cheap", 5*0.01 + 1*0.02},
+		{"unpriced model without usage", "/none", "unknown", 5*0.03 + 1*0.02},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				c := NewClient(WithBudgetGuard(100, models))
+				req := &Request{Provider: srv.URL + tt.path, Model: tt.model, Prompt: "hi"}
+				var err error
+				if stream {
+					_, _, err = collectStream(t, c, req)
+				} else {
+					_, err = c.Send(context.Background(), req)
+				}
+				if err != nil {
+					t.Fatalf("request: %v", err)
+				}
+				if spent := c.BudgetSpent(); math.Abs(spent-tt.want) > 1e-9 {
+					t.Errorf("BudgetSpent() = %v, want %v", spent, tt.want)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if stream && !streamUsage {
+					t.Error("stream request did not ask for usage")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+type ChunkJoiner func(results []string) string
+
+func SplitPromptByTokens(text string, maxTokens int, estimate TokenEstimator) []string {
+	if estimate == nil {
+		estimate = EstimateTokens
+	}
+	if maxTokens <= 0 || estimate(text) <= maxTokens {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current string
+	add := func(unit, sep string) {
+		if current == "" {
+			current = unit
+			return
+		}
+		if estimate(current+sep+unit) <= maxTokens {
+			current += sep + unit
+			return
+		}
+		chunks = append(chunks, current)
+		current = unit
+	}
+
+	for _, para := range strings.Split(text, "\n\n") {
+		if estimate(para) <= maxTokens {
+			add(para, "\n\n")
+			continue
+		}
+		first := true
+		for _, word := range strings.Fields(para) {
+			sep := " "
+			if first {
+				sep = "\n\n"
+				first = false
+			}
+			for _, piece := range splitOversized(word, maxTokens, estimate) {
+				add(piece, sep)
+				sep = ""
+			}
+		}
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// splitOversized hard-splits a single word that doesn't fit on its own.
+func splitOversized(word string, maxTokens int, estimate TokenEstimator) []string {
+	if estimate(word) <= maxTokens {
+		return []string{word}
+	}
+	var pieces []string
+	runes := []rune(word)
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		if estimate(string(runes[start:i])) > maxTokens && i-1 > start {
+			pieces = append(pieces, string(runes[start:i-1]))
+			start = i - 1
+		}
+	}
+	return append(pieces, string(runes[start:]))
+}
+
+// SendChunked splits req.Prompt into chunks of at most maxTokens, sends each
+// chunk with the request's system prompt and combines the results. When
+// joiner is set, its output is sent as a final reduce prompt; otherwise the
+// partial results are concatenated.
+func (c *Client) SendChunked(ctx context.Context, req *Request, maxTokens int, joiner ChunkJoiner) (*Response, error) {
+	if req == nil {
+		return nil, errors.New("request is nil")
+	}
+
+	chunks := SplitPromptByTokens(req.Prompt, maxTokens, EstimateTokens)
+	if len(chunks) == 1 {
+		return c.Send(ctx, req)
+	}
+
+	results := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		part := *req
+		part.Messages = nil
+		part.Prompt = chunk
+		resp, err := c.Send(ctx, &part)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, resp.Content)
+	}
+
+	if joiner == nil {
+		return &Response{Content: strings.Join(results, "\n\n")}, nil
+	}
+
+	reduce := *req
+	reduce.Messages = nil
+	reduce.Prompt = joiner(results)
+	return c.Send(ctx, &reduce)
+}
@@ -0,0 +1,87 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSplitPromptByTokens(t *testing.T) {
+	words := func(s string) int { return len(strings.Fields(s)) }
+	tests := []struct {
+		name      string
+		text      string
+		maxTokens int
+		estimate  TokenEstimator
+		want      []string
+	}{
+		{"fits", "a b c", 5, words, []string{"a b c"}},
+		{"no limit", "a b c", 0, words, []string{"a b c"}},
+		{"paragraphs packed together", "a b\n\nc d\n\ne f", 4, words, []string{"a b\n\nc d", "e f"}},
+		{"long paragraph split by words", "a b c d e", 2, words, []string{"a b", "c d", "e"}},
+		{"long paragraph after a short one", "a\n\nb c d", 2, words, []string{"a\n\nb", "c d"}},
+		{"oversized word hard-split", "abcdefghijkl", 1, nil, []string{"abcd", "efgh", "ijkl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitPromptByTokens(tt.text, tt.maxTokens, tt.estimate)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitPromptByTokens(%q, %d) = %q, want %q", tt.text, tt.maxTokens, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendChunkedMapReduce(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		prompts []string
+		systems []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		msgs, _ := decodeBody(t, body)["messages"].([]interface{})
+		system := msgs[0].(map[string]interface{})["content"].(string)
+		prompt := msgs[len(msgs)-1].(map[string]interface{})["content"].(string)
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		systems = append(systems, system)
+		mu.Unlock()
+		io.WriteString(w, `{"choices":[{"message":{"content":"got `+prompt+`"}}]}`)
+	}))
+	defer srv.Close()
+
+	// "aaaa bbbb" estimates at 3 tokens, so a limit of 2 yields two chunks.
+	req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", SystemPrompt: "summarize", Prompt: "aaaa bbbb"}
+	joiner := func(results []string) string { return strings.Join(results, " | ") }
+	resp, err := NewClient().SendChunked(context.Background(), req, 2, joiner)
+	if err != nil {
+		t.Fatalf("SendChunked: %v", err)
+	}
+
+	wantPrompts := []string{"aaaa", "bbbb", "got aaaa | got bbbb"}
+	if !reflect.DeepEqual(prompts, wantPrompts) {
+		t.Errorf("prompts = %q, want %q", prompts, wantPrompts)
+	}
+	for i, s := range systems {
+		if s != "summarize" {
+			t.Errorf("request %d system prompt = %q", i+1, s)
+		}
+	}
+	if resp.Content != "got got aaaa | got bbbb" {
+		t.Errorf("content = %q", resp.Content)
+	}
+
+	resp, err = NewClient().SendChunked(context.Background(), req, 2, nil)
+	if err != nil {
+		t.Fatalf("SendChunked without joiner: %v", err)
+	}
+	if resp.Content != "got aaaa\n\ngot bbbb" {
+		t.Errorf("concatenated content = %q", resp.Content)
+	}
+}
@@ -0,0 +1,61 @@
+package llmclient
+
+import "encoding/json"
+
+// Citation is a source a search-augmented model referred to. StartIndex and
+// EndIndex mark the cited span of Response.Content when the provider sends
+// them.
+type Citation struct {
+	URL        string
+	Title      string
+	Content    string
+	StartIndex int
+	EndIndex   int
+}
+
+// parseCitations reads url_citation annotations from choices[0].message
+// (OpenAI, OpenRouter) and falls back to Perplexity's top-level list of URLs.
+func parseCitations(body []byte) []Citation {
+	var r struct {
+		Citations []string `json:"citations"`
+		Choices   []struct {
+			Message struct {
+				Annotations []struct {
+					Type        string `json:"type"`
+					URLCitation struct {
+						URL        string `json:"url"`
+						Title      string `json:"title"`
+						Content    string `json:"content"`
+						StartIndex int    `json:"start_index"`
+						EndIndex   int    `json:"end_index"`
+					} `json:"url_citation"`
+				} `json:"annotations"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil
+	}
+
+	var citations []Citation
+	if len(r.Choices) > 0 {
+		for _, a := range r.Choices[0].Message.Annotations {
+			if a.Type != "url_citation" || a.URLCitation.URL == "" {
+				continue
+			}
+			citations = append(citations, Citation{
+				URL:        a.URLCitation.URL,
+				Title:      a.URLCitation.Title,
+				Content:    a.URLCitation.Content,
+				StartIndex: a.URLCitation.StartIndex,
+				EndIndex:   a.URLCitation.EndIndex,
+			})
+		}
+	}
+	if len(citations) == 0 {
+		for _, url := range r.Citations {
+			citations = append(citations, Citation{URL: url})
+		}
+	}
+	return citations
+}
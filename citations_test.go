@@ -0,0 +1,67 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// openAISearchResponse is a gpt-4o-search-preview reply with url_citation
+// annotations and one annotation of another type.
+const openAISearchResponse = `{
+	"id": "chatcmpl-1",
+	"model": "gpt-4o-search-preview-2025-03-11",
+	"choices": [{
+		"index": 0,
+		"finish_reason": "stop",
+		"message": {
+			"role": "assistant",
+			"content": "Go 1.22 added range over integers.",
+			"annotations": [
+				{"type": "url_citation", "url_citation": {"url": "https://go.dev/doc/go1.22", "title": "Go 1.22 Release Notes", "start_index": 0, "end_index": 33}},
+				{"type": "file_citation", "file_citation": {"file_id": "file-1"}},
+				{"type": "url_citation", "url_citation": {"url": ""}}
+			]
+		}
+	}]
+}`
+
+func TestParseCitations(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Citation
+	}{
+		{"openai annotations", openAISearchResponse, []Citation{
+			{URL: "https://go.dev/doc/go1.22", Title: "Go 1.22 Release Notes", StartIndex: 0, EndIndex: 33},
+		}},
+		{"openrouter web plugin", `{"choices":[{"message":{"content":"x","annotations":[
+			{"type":"url_citation","url_citation":{"url":"https://example.com/a","title":"A","content":"snippet","start_index":2,"end_index":9}}]}}]}`,
+			[]Citation{{URL: "https://example.com/a", Title: "A", Content: "snippet", StartIndex: 2, EndIndex: 9}}},
+		{"perplexity list", `{"citations":["https://a.example","https://b.example"],"choices":[{"message":{"content":"x"}}]}`,
+			[]Citation{{URL: "https://a.example"}, {URL: "https://b.example"}}},
+		{"none", `{"choices":[{"message":{"content":"x"}}]}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCitations([]byte(tt.body)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("citations = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, openAISearchResponse)
+	}))
+	defer srv.Close()
+	resp, err := NewClient().Send(context.Background(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "What changed in Go 1.22?"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(resp.Citations) != 1 || resp.Citations[0].URL != "https://go.dev/doc/go1.22" {
+		t.Errorf("Response.Citations = %+v", resp.Citations)
+	}
+}
@@ -3,6 +3,7 @@ package llmclient
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,6 +18,7 @@ const (
 	defaultTimeout       = 120 * time.Second
 	defaultOllamaURL     = "http://localhost:11434/v1/chat/completions"
 	defaultOpenRouterURL = "https://openrouter.ai/api/v1/chat/completions"
+	defaultOpenAIURL     = "https://api.openai.com/v1/chat/completions"
 	// Pollinations endpoints:
 	// - pollinationsFreeURL: используется без API-ключа (бесплатный доступ)
 	// - pollinationsPaidURL: используется с API-ключом (платный доступ)
@@ -27,14 +29,82 @@ const (
 var defaultHTTPClient = &http.Client{Timeout: defaultTimeout}
 
 type Client struct {
-	httpClient *http.Client
+	httpClient              *http.Client
+	budget                  *budgetGuard
+	forceHTTP1              bool
+	retry                   *retryPolicy
+	retryStatusCodes        []int
+	onRetry                 func(attempt int, err error, nextDelay time.Duration)
+	systemPrompt            string
+	fewShot                 []Message
+	injectionOrder          []InjectionSlot
+	apiKeys                 map[string]*keyRing
+	logger                  func(LogEvent)
+	providerSlots           map[string]chan struct{}
+	streamIdleTimeout       time.Duration
+	streamFirstTokenTimeout time.Duration
+	streamFallback          bool
+	modelCatalog            *modelCatalog
+	contextModels           []Model
+	tokenEstimator          TokenEstimator
+	noLogRedaction          bool
+	modelAliases            map[string]string
+	metrics                 MetricsRecorder
+	noSystemPrompt          map[string]bool
+	providerDefaults        map[string]string
+	defaultTemperature      *float64
+	defaultTopP             *float64
+	defaultMaxTokens        *int
+	endpoints               map[string]string
+	maxRequestBytes         int64
+	captureHeaders          []string
+	errorOnEmptyContent     bool
+	maxStreamLineSize       int
+	maxHistoryMessages      int
+	deprecatedModels        map[string]string
+	onModelSubstitution     func(deprecated, replacement string)
+	usage                   *UsageTracker
 }
 
 func NewClient(opts ...ClientOption) *Client {
-	c := &Client{httpClient: defaultHTTPClient}
+	c := &Client{httpClient: defaultHTTPClient, usage: &UsageTracker{}}
 	for _, opt := range opts {
 		opt(c)
 	}
+	if c.forceHTTP1 {
+		c.httpClient = forceHTTP1(c.httpClient)
+	}
+	if c.logger != nil {
+		c.httpClient = wrapTransport(c.httpClient, func(rt http.RoundTripper) http.RoundTripper {
+			return &loggingTransport{base: rt, log: c.logger, redact: !c.noLogRedaction}
+		})
+	}
+	if c.retry != nil {
+		codes := defaultRetryableStatusCodes
+		if c.retryStatusCodes != nil {
+			codes = c.retryStatusCodes
+		}
+		c.retry.statusCodes = newStatusCodeSet(codes)
+		c.retry.onRetry = c.onRetry
+		c.httpClient = wrapTransport(c.httpClient, func(rt http.RoundTripper) http.RoundTripper {
+			return &retryTransport{base: rt, policy: c.retry}
+		})
+	}
+	if c.metrics != nil {
+		c.httpClient = wrapTransport(c.httpClient, func(rt http.RoundTripper) http.RoundTripper {
+			return &byteCountTransport{base: rt}
+		})
+	}
+	if len(c.captureHeaders) > 0 {
+		c.httpClient = wrapTransport(c.httpClient, func(rt http.RoundTripper) http.RoundTripper {
+			return &headerCaptureTransport{base: rt, names: c.captureHeaders}
+		})
+	}
+	if c.maxRequestBytes > 0 {
+		c.httpClient = wrapTransport(c.httpClient, func(rt http.RoundTripper) http.RoundTripper {
+			return &requestLimitTransport{base: rt, limit: c.maxRequestBytes}
+		})
+	}
 	return c
 }
 
@@ -44,21 +114,67 @@ func WithHTTPClient(hc *http.Client) ClientOption {
 	return func(c *Client) { c.httpClient = hc }
 }
 
+// HTTPClient returns the HTTP client with the retry, logging, byte counting,
+// header capture and request size layers configured on c. Providers added
+// with RegisterChatProvider should send their requests through it.
+func (c *Client) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
+func WithSystemPrompt(prompt string) ClientOption {
+	return func(c *Client) { c.systemPrompt = prompt }
+}
+
+func WithFewShot(examples []Message) ClientOption {
+	return func(c *Client) { c.fewShot = append([]Message(nil), examples...) }
+}
+
+func WithModelAliases(aliases map[string]string) ClientOption {
+	return func(c *Client) {
+		c.modelAliases = make(map[string]string, len(aliases))
+		for alias, model := range aliases {
+			c.modelAliases[strings.ToLower(alias)] = model
+		}
+	}
+}
+
 type Message struct {
 	Role         string
 	Content      string
 	ContentParts []ContentPart
+	ToolCallID   string
+	ToolCalls    []ToolCall
+}
+
+// ToolCall is a function call requested by the model, as returned in an
+// assistant message's tool_calls.
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 type ContentPart struct {
 	Type     string    `json:"type"`
 	Text     string    `json:"text,omitempty"`
 	ImageURL *ImageURL `json:"image_url,omitempty"`
+	File     *FileRef  `json:"file,omitempty"`
 }
 
 type ImageURL struct {
 	URL    string `json:"url"`
 	Detail string `json:"detail,omitempty"`
+	// MimeType is used when URL holds raw base64 data instead of a URL.
+	MimeType string `json:"-"`
+}
+
+type FileRef struct {
+	FileID string `json:"file_id"`
 }
 
 func NewTextPart(text string) ContentPart {
@@ -77,23 +193,65 @@ func NewImageBase64Part(mediaType, base64Data string) ContentPart {
 	return ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: "data:" + mediaType + ";base64," + base64Data}}
 }
 
+func NewImageBase64PartWithDetail(mediaType, base64Data, detail string) ContentPart {
+	return ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: base64Data, Detail: detail, MimeType: mediaType}}
+}
+
+func NewFilePart(fileID string) ContentPart {
+	return ContentPart{Type: "file", File: &FileRef{FileID: fileID}}
+}
+
 type Request struct {
-	Provider     string
-	Model        string
-	APIKey       string
-	SystemPrompt string
-	Prompt       string
-	Messages     []Message
-	Images       []string
-	Endpoint     string
-	Temperature  *float64
-	MaxTokens    *int
-	Seed         *int
+	Provider             string
+	Model                string
+	APIKey               string
+	SystemPrompt         string
+	Prompt               string
+	Messages             []Message
+	Images               []string
+	Endpoint             string
+	Temperature          *float64
+	TopP                 *float64
+	FrequencyPenalty     *float64
+	PresencePenalty      *float64
+	MaxTokens            *int
+	Seed                 *int
+	Stop                 []string
+	ClientSideStop       bool
+	FallbackModels       []string
+	OpenRouterTransforms []string
+	WebSearch            bool
+	PromptCacheTTL       string
+	SafePrompt           *bool
+	Store                *bool
+	Metadata             map[string]string
+	ValidateMessages     bool
+	Locale               string // sent as Accept-Language only; no provider takes a payload field for it
+	JSONSchema           *JSONSchema
+	StreamBuffer         int
+	Completion           bool
+	RetrievedContext     []string
+	StreamUsage          bool
+	Tools                []map[string]interface{}
 }
 
 type Response struct {
-	Content string
-	Raw     []byte
+	Content           string
+	ContentParts      []ContentPart
+	Model             string
+	FinishReason      string
+	Usage             *TokenUsage
+	Headers           map[string]string
+	Raw               []byte
+	SafetyAnnotations []SafetyAnnotation
+	Citations         []Citation
+	ToolCalls         []ToolCall
+}
+
+type TokenUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
 }
 
 func (c *Client) Send(ctx context.Context, req *Request) (*Response, error) {
@@ -101,129 +259,372 @@ func (c *Client) Send(ctx context.Context, req *Request) (*Response, error) {
 		return nil, errors.New("request is nil")
 	}
 
-	provider, err := c.newProvider(req)
+	if err := c.budget.check(); err != nil {
+		return nil, err
+	}
+	req = c.applyDefaults(req)
+	if err := validateRequestMessages(req); err != nil {
+		return nil, err
+	}
+	model := c.lookupModel(ctx, req)
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
 	if err != nil {
 		return nil, err
 	}
-
-	history := req.Messages
-	if len(history) == 0 && req.Prompt != "" {
-		history = []Message{{Role: "user", Content: req.Prompt}}
+	defer release()
+	adaptToModel(req, model)
+	history := c.buildHistory(req)
+	if c.rejectsSystemPrompt(req.Model) {
+		history = foldSystemPrompt(req.SystemPrompt, history)
+		req.SystemPrompt = ""
+	}
+	if err := c.checkFits(req.Model, req.SystemPrompt, history); err != nil {
+		return nil, err
 	}
 
-	content, err := provider.Send(ctx, history, req.Images, req.SystemPrompt)
+	resp, err := c.send(ctx, req, history)
 	if err != nil {
 		return nil, err
 	}
+	if req.JSONSchema != nil {
+		if resp, err = c.validateJSONResponse(ctx, req, history, resp); err != nil {
+			return nil, err
+		}
+	}
+	if c.errorOnEmptyContent && resp.Content == "" && len(resp.ContentParts) == 0 && len(resp.ToolCalls) == 0 {
+		return nil, ErrEmptyContent
+	}
+	resp.Headers = captured.headers()
+	return resp, nil
+}
 
-	return &Response{Content: content}, nil
+func (c *Client) send(ctx context.Context, req *Request, history []Message) (*Response, error) {
+	var lastErr error
+	for _, model := range requestModels(req) {
+		resp, err := c.sendModel(ctx, req, model, history)
+		if err != nil {
+			if replacement, ok := c.deprecationReplacement(model, err); ok {
+				model = replacement
+				resp, err = c.sendModel(ctx, req, model, history)
+			}
+		}
+		if err != nil {
+			if isFailoverError(err) && ctx.Err() == nil {
+				lastErr = err
+				continue
+			}
+			return nil, err
+		}
+
+		c.budget.record(model, c.billedUsage(resp.Usage, req.SystemPrompt, history, resp.Content))
+		c.usage.record(resp.Usage)
+		c.observeResponseBytes(req.Provider, len(resp.Raw))
+		return resp, nil
+	}
+	return nil, lastErr
 }
 
-func (c *Client) newProvider(req *Request) (provider, error) {
-	name := strings.ToLower(strings.TrimSpace(req.Provider))
+func (c *Client) sendModel(ctx context.Context, req *Request, model string, history []Message) (*Response, error) {
+	attempt := *req
+	attempt.Model = model
 
-	switch name {
-	case "ollama":
-		endpoint := req.Endpoint
-		if endpoint == "" {
-			endpoint = defaultOllamaURL
+	keys := c.requestKeys(req)
+	for i := 0; ; i++ {
+		attempt.APIKey = keys[i]
+		provider, err := c.newProvider(&attempt)
+		if err != nil {
+			return nil, err
 		}
-		return &ollamaProvider{model: req.Model, endpoint: endpoint, client: c.httpClient}, nil
-	case "pollinations":
-		return &pollinationsProvider{model: req.Model, key: req.APIKey, client: c.httpClient, seed: req.Seed}, nil
-	case "openrouter":
-		return &openRouterProvider{model: req.Model, key: req.APIKey, client: c.httpClient}, nil
-	default:
-		if isURL(name) {
-			return &genericProvider{endpoint: name, model: req.Model, key: req.APIKey, client: c.httpClient}, nil
+		resp, err := provider.Send(ctx, history, req.Images, req.SystemPrompt)
+		if err != nil && isKeyRejected(err) && i < len(keys)-1 && ctx.Err() == nil {
+			continue
 		}
-		if isURL(req.Endpoint) {
-			return &genericProvider{endpoint: req.Endpoint, model: req.Model, key: req.APIKey, client: c.httpClient}, nil
+		return resp, withProvider(err, req.Provider)
+	}
+}
+
+func (c *Client) RawChat(ctx context.Context, url string, payload map[string]any, key string) ([]byte, error) {
+	if !isURL(url) {
+		return nil, fmt.Errorf("invalid url: %s", url)
+	}
+	return postJSON(ctx, c.httpClient, url, payload, key, nil)
+}
+
+// applyDefaults returns a copy of req with client-level defaults filled in,
+// leaving the caller's request untouched.
+func (c *Client) applyDefaults(req *Request) *Request {
+	r := *req
+	if r.SystemPrompt == "" {
+		r.SystemPrompt = c.systemPrompt
+	}
+	if r.Model == "" {
+		r.Model = c.providerDefaults[strings.ToLower(strings.TrimSpace(r.Provider))]
+	}
+	if r.Temperature == nil {
+		r.Temperature = c.defaultTemperature
+	}
+	if r.TopP == nil {
+		r.TopP = c.defaultTopP
+	}
+	if r.MaxTokens == nil {
+		r.MaxTokens = c.defaultMaxTokens
+	}
+	r.Model = c.resolveModel(r.Model)
+	if len(r.FallbackModels) > 0 && len(c.modelAliases) > 0 {
+		fallbacks := make([]string, len(r.FallbackModels))
+		for i, m := range r.FallbackModels {
+			fallbacks[i] = c.resolveModel(m)
+		}
+		r.FallbackModels = fallbacks
+	}
+	return &r
+}
+
+func WithProviderDefaults(models map[string]string) ClientOption {
+	return func(c *Client) {
+		c.providerDefaults = make(map[string]string, len(models))
+		for provider, model := range models {
+			c.providerDefaults[strings.ToLower(strings.TrimSpace(provider))] = model
 		}
-		return nil, fmt.Errorf("unknown provider: %s", req.Provider)
 	}
 }
 
-type provider interface {
-	Send(ctx context.Context, history []Message, images []string, systemPrompt string) (string, error)
+// WithDefaultTemperature, WithDefaultTopP and WithDefaultMaxTokens set
+// sampling defaults for chat and stream requests that leave them unset.
+func WithDefaultTemperature(temp float64) ClientOption {
+	return func(c *Client) { c.defaultTemperature = &temp }
+}
+
+func WithDefaultTopP(topP float64) ClientOption {
+	return func(c *Client) { c.defaultTopP = &topP }
+}
+
+func WithDefaultMaxTokens(max int) ClientOption {
+	return func(c *Client) { c.defaultMaxTokens = &max }
+}
+
+func WithEndpoints(endpoints map[string]string) ClientOption {
+	return func(c *Client) {
+		c.endpoints = make(map[string]string, len(endpoints))
+		for provider, url := range endpoints {
+			c.endpoints[strings.ToLower(strings.TrimSpace(provider))] = url
+		}
+	}
+}
+
+func (c *Client) resolveModel(model string) string {
+	if resolved, ok := c.modelAliases[strings.ToLower(model)]; ok {
+		return resolved
+	}
+	return model
+}
+
+// buildHistory assembles the messages sent after the system prompt: by
+// default client few-shot examples first, then retrieved context, then the
+// request's own history or prompt. The examples are added per call and never
+// written back into req.Messages. When the injection order moves the system
+// prompt behind other messages, it is inserted there and req.SystemPrompt is
+// cleared, so req must be the per-call copy from applyDefaults.
+func (c *Client) buildHistory(req *Request) []Message {
+	history := trimHistory(req.Messages, c.maxHistoryMessages)
+	if len(history) == 0 && req.Prompt != "" {
+		history = []Message{{Role: "user", Content: req.Prompt}}
+	}
+	retrieved := contextText(req.RetrievedContext)
+	if c.injectionOrder == nil && len(c.fewShot) == 0 && retrieved == "" {
+		return history
+	}
+
+	order := c.injectionOrder
+	if order == nil {
+		order = defaultInjectionOrder
+	}
+	// System text placed before any message joins the system prompt; placed
+	// after few-shot examples or history, it goes into the first user turn
+	// of the history, so no system message ends up mid-conversation.
+	var leading, folded []string
+	addSystem := func(msgs []Message, text string) {
+		switch {
+		case text == "":
+		case len(msgs) == 0:
+			leading = append(leading, text)
+		default:
+			folded = append(folded, text)
+		}
+	}
+	msgs := make([]Message, 0, len(c.fewShot)+len(history)+1)
+	historyStart := 0
+	for _, slot := range order {
+		switch slot {
+		case InjectSystem:
+			addSystem(msgs, req.SystemPrompt)
+		case InjectFewShot:
+			msgs = append(msgs, c.fewShot...)
+		case InjectContext:
+			addSystem(msgs, retrieved)
+		case InjectHistory:
+			historyStart = len(msgs)
+			msgs = append(msgs, history...)
+		}
+	}
+	req.SystemPrompt = strings.Join(leading, "\n\n")
+	if len(folded) > 0 {
+		historyEnd := historyStart + len(history)
+		turns := prependToFirstUser(strings.Join(folded, "\n\n"), msgs[historyStart:historyEnd])
+		out := append(append([]Message{}, msgs[:historyStart]...), turns...)
+		msgs = append(out, msgs[historyEnd:]...)
+	}
+	return msgs
+}
+
+func requestModels(req *Request) []string {
+	models := make([]string, 0, 1+len(req.FallbackModels))
+	models = append(models, req.Model)
+	for _, m := range req.FallbackModels {
+		if m != "" && m != req.Model {
+			models = append(models, m)
+		}
+	}
+	return models
 }
 
 type ollamaProvider struct {
 	model    string
 	endpoint string
+	key      string
 	client   *http.Client
+	header   http.Header
+	native   bool
+	chatOptions
 }
 
-func (p *ollamaProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (string, error) {
-	msgs := messagesToMaps(history, images, systemPrompt)
-	payload := map[string]interface{}{"model": p.model, "messages": msgs, "stream": false}
-	respBody, err := postJSON(ctx, p.client, p.endpoint, payload, "")
+func (p *ollamaProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	if p.native {
+		respBody, err := postJSON(ctx, p.client, p.endpoint, p.nativePayload(history, images, systemPrompt, false), p.key, p.header)
+		if err != nil {
+			return nil, err
+		}
+		return parseOllamaResponse(respBody)
+	}
+	respBody, err := postJSON(ctx, p.client, p.endpoint, p.newPayload(p.model, history, images, systemPrompt, false), p.key, p.header)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return extractContent(respBody)
+	return parseResponse(respBody)
 }
 
 type pollinationsProvider struct {
 	model  string
 	key    string
+	url    string
 	client *http.Client
-	seed   *int
+	header http.Header
+	chatOptions
 }
 
-func (p *pollinationsProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (string, error) {
-	msgs := messagesToMaps(history, images, systemPrompt)
-	payload := map[string]interface{}{"model": p.model, "messages": msgs}
-	if p.seed != nil {
-		payload["seed"] = *p.seed
+func (p *pollinationsProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	payload := p.newPayload(p.model, history, images, systemPrompt, false)
+	respBody, err := postJSON(ctx, p.client, p.endpoint(), payload, p.key, p.header)
+	if err != nil {
+		return nil, err
 	}
+	return parseResponse(respBody)
+}
 
-	// Pollinations без API-ключа использует бесплатный endpoint text.pollinations.ai/openai.
-	// Этот endpoint не требует авторизации и доступен для бесплатного использования.
-	// С API-ключом используется gen.pollinations.ai/v1/chat/completions.
-	endpoint := pollinationsPaidURL
+// endpoint выбирает URL Pollinations одинаково для обычных и потоковых запросов.
+// Без API-ключа используется бесплатный endpoint text.pollinations.ai/openai,
+// который не требует авторизации. С API-ключом используется
+// gen.pollinations.ai/v1/chat/completions.
+func (p *pollinationsProvider) endpoint() string {
+	if p.url != "" {
+		return p.url
+	}
 	if p.key == "" {
-		endpoint = pollinationsFreeURL
+		return pollinationsFreeURL
 	}
+	return pollinationsPaidURL
+}
+
+type openRouterProvider struct {
+	model      string
+	key        string
+	endpoint   string
+	client     *http.Client
+	header     http.Header
+	transforms []string
+	webSearch  bool
+	chatOptions
+}
 
-	respBody, err := postJSON(ctx, p.client, endpoint, payload, p.key)
+func (p *openRouterProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	payload := p.payload(history, images, systemPrompt, false)
+	respBody, err := postJSON(ctx, p.client, p.endpoint, payload, p.key, p.header)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return extractContent(respBody)
+	return parseResponse(respBody)
 }
 
-type openRouterProvider struct {
-	model  string
-	key    string
-	client *http.Client
+func (p *openRouterProvider) payload(history []Message, images []string, systemPrompt string, stream bool) *chatPayload {
+	payload := p.newPayload(p.model, history, images, systemPrompt, stream)
+	payload.Transforms = p.transforms
+	if p.webSearch && !strings.HasSuffix(payload.Model, ":online") {
+		payload.Model += ":online"
+	}
+	return payload
+}
+
+type openAIProvider struct {
+	model     string
+	key       string
+	endpoint  string
+	client    *http.Client
+	header    http.Header
+	webSearch bool
+	chatOptions
 }
 
-func (p *openRouterProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (string, error) {
-	msgs := messagesToMaps(history, images, systemPrompt)
-	payload := map[string]interface{}{"model": p.model, "messages": msgs}
-	respBody, err := postJSON(ctx, p.client, defaultOpenRouterURL, payload, p.key)
+func (p *openAIProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	payload := p.payload(history, images, systemPrompt, false)
+	respBody, err := postJSON(ctx, p.client, p.endpoint, payload, p.key, p.header)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return parseResponse(respBody)
+}
+
+func (p *openAIProvider) payload(history []Message, images []string, systemPrompt string, stream bool) *chatPayload {
+	payload := p.newPayload(p.model, history, images, systemPrompt, stream)
+	if p.webSearch {
+		payload.WebSearchOptions = &webSearchOptions{}
 	}
-	return extractContent(respBody)
+	return payload
 }
 
 type genericProvider struct {
-	endpoint string
-	model    string
-	key      string
-	client   *http.Client
+	endpoint   string
+	model      string
+	key        string
+	client     *http.Client
+	header     http.Header
+	completion bool
+	chatOptions
 }
 
-func (p *genericProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (string, error) {
-	msgs := messagesToMaps(history, images, systemPrompt)
-	payload := map[string]interface{}{"model": p.model, "messages": msgs}
-	respBody, err := postJSON(ctx, p.client, p.endpoint, payload, p.key)
+func (p *genericProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	respBody, err := postJSON(ctx, p.client, p.endpoint, p.payload(history, images, systemPrompt, false), p.key, p.header)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return parseResponse(respBody)
+}
+
+func (p *genericProvider) payload(history []Message, images []string, systemPrompt string, stream bool) interface{} {
+	if p.completion {
+		return p.newCompletionPayload(p.model, history, systemPrompt, stream)
 	}
-	return extractContent(respBody)
+	return p.newPayload(p.model, history, images, systemPrompt, stream)
 }
 
 func messagesToMaps(history []Message, images []string, systemPrompt string) []map[string]interface{} {
@@ -232,21 +633,24 @@ func messagesToMaps(history []Message, images []string, systemPrompt string) []m
 		msgs = append(msgs, map[string]interface{}{"role": "system", "content": systemPrompt})
 	}
 	for i, m := range history {
-		if i == len(history)-1 && m.Role == "user" {
-			if len(m.ContentParts) > 0 {
-				msgs = append(msgs, map[string]interface{}{"role": m.Role, "content": contentPartsToSlice(m.ContentParts)})
-			} else if len(images) > 0 {
-				msgs = append(msgs, map[string]interface{}{"role": m.Role, "content": buildMessageContent(m.Content, images)})
-			} else {
-				msgs = append(msgs, map[string]interface{}{"role": m.Role, "content": m.Content})
-			}
-		} else {
-			if len(m.ContentParts) > 0 {
-				msgs = append(msgs, map[string]interface{}{"role": m.Role, "content": contentPartsToSlice(m.ContentParts)})
-			} else {
-				msgs = append(msgs, map[string]interface{}{"role": m.Role, "content": m.Content})
-			}
+		msg := map[string]interface{}{"role": m.Role}
+		if m.ToolCallID != "" {
+			msg["tool_call_id"] = m.ToolCallID
+		}
+		if len(m.ToolCalls) > 0 {
+			msg["tool_calls"] = m.ToolCalls
 		}
+		switch {
+		case len(m.ToolCalls) > 0 && m.Content == "" && len(m.ContentParts) == 0:
+			msg["content"] = nil
+		case len(m.ContentParts) > 0:
+			msg["content"] = contentPartsToSlice(m.ContentParts)
+		case i == len(history)-1 && m.Role == "user" && len(images) > 0:
+			msg["content"] = buildMessageContent(m.Content, images)
+		default:
+			msg["content"] = m.Content
+		}
+		msgs = append(msgs, msg)
 	}
 	return msgs
 }
@@ -258,10 +662,9 @@ func contentPartsToSlice(parts []ContentPart) []map[string]interface{} {
 		if p.Type == "text" {
 			part["text"] = p.Text
 		} else if p.Type == "image_url" && p.ImageURL != nil {
-			part["image_url"] = map[string]interface{}{"url": p.ImageURL.URL}
-			if p.ImageURL.Detail != "" {
-				part["image_url"].(map[string]interface{})["detail"] = p.ImageURL.Detail
-			}
+			part = newImagePart(p.ImageURL).openAI()
+		} else if p.Type == "file" && p.File != nil {
+			part["file"] = map[string]interface{}{"file_id": p.File.FileID}
 		}
 		result[i] = part
 	}
@@ -274,16 +677,49 @@ func buildMessageContent(content string, images []string) interface{} {
 	}
 	parts := []map[string]interface{}{{"type": "text", "text": content}}
 	for _, img := range images {
-		parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": map[string]string{"url": img}})
+		parts = append(parts, imagePart{url: img}.openAI())
 	}
 	return parts
 }
 
+// guessBase64ImageType detects the MIME type from the decoded bytes, falling
+// back to image/png.
+func guessBase64ImageType(data string) string {
+	// 512 bytes is all http.DetectContentType looks at; decode just enough.
+	head := data
+	if len(head) > 684 {
+		head = head[:684]
+	}
+	head = head[:len(head)/4*4]
+	decoded, err := base64.StdEncoding.DecodeString(head)
+	if err != nil {
+		return "image/png"
+	}
+	if mediaType := http.DetectContentType(decoded); strings.HasPrefix(mediaType, "image/") {
+		return mediaType
+	}
+	return "image/png"
+}
+
+func requestHeader(req *Request) http.Header {
+	header := make(http.Header)
+	if req.Locale != "" {
+		header.Set("Accept-Language", req.Locale)
+	}
+	return header
+}
+
+func setHeaders(req *http.Request, header http.Header) {
+	for k, v := range header {
+		req.Header[k] = v
+	}
+}
+
 func isURL(s string) bool {
 	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
 }
 
-func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}, key string) ([]byte, error) {
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}, key string, header http.Header) ([]byte, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("marshal: %w", err)
@@ -293,6 +729,7 @@ func postJSON(ctx context.Context, client *http.Client, url string, payload inte
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	setHeaders(req, header)
 	if key != "" {
 		req.Header.Set("Authorization", "Bearer "+key)
 	}
@@ -310,11 +747,71 @@ func postJSON(ctx context.Context, client *http.Client, url string, payload inte
 		return nil, fmt.Errorf("read response: %w", err)
 	}
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(respBytes))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBytes)}
+	}
+	if err := checkContentType(resp, respBytes); err != nil {
+		return nil, err
 	}
 	return respBytes, nil
 }
 
+func parseResponse(body []byte) (*Response, error) {
+	var meta struct {
+		Model   string      `json:"model"`
+		Usage   *TokenUsage `json:"usage"`
+		Choices []struct {
+			Message struct {
+				Content   json.RawMessage `json:"content"`
+				ToolCalls []ToolCall      `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &meta); err != nil {
+		content, err := extractContent(body)
+		if err != nil {
+			return nil, err
+		}
+		return &Response{Content: content, Raw: body}, nil
+	}
+
+	resp := &Response{Model: meta.Model, Usage: meta.Usage, Raw: body, SafetyAnnotations: parseSafetyAnnotations(body), Citations: parseCitations(body)}
+
+	// Multimodal replies carry content as an array of typed parts.
+	if len(meta.Choices) > 0 {
+		resp.FinishReason = meta.Choices[0].FinishReason
+		resp.ToolCalls = meta.Choices[0].Message.ToolCalls
+		var parts []ContentPart
+		if err := json.Unmarshal(meta.Choices[0].Message.Content, &parts); err == nil && len(parts) > 0 {
+			resp.ContentParts = parts
+			resp.Content = contentPartsText(parts)
+			return resp, nil
+		}
+	}
+
+	content, err := extractContent(body)
+	if err != nil {
+		// A well-formed reply with choices but no text (empty or null
+		// content, or only tool calls) is empty content, not a parse error.
+		if len(meta.Choices) > 0 {
+			return resp, nil
+		}
+		return nil, err
+	}
+	resp.Content = content
+	return resp, nil
+}
+
+func contentPartsText(parts []ContentPart) string {
+	var b strings.Builder
+	for _, p := range parts {
+		if p.Type == "text" {
+			b.WriteString(p.Text)
+		}
+	}
+	return b.String()
+}
+
 func extractContent(body []byte) (string, error) {
 	return extractContentFromPossibleJSON(string(body))
 }
@@ -0,0 +1,496 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestFallbackModels(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantModels []string
+		wantErr    bool
+	}{
+		{"404 fails over", 404, `{"error":{"message":"not here"}}`, []string{"premium", "cheap"}, false},
+		{"model_not_found fails over", 400, `{"error":{"code":"model_not_found","message":"The model does not exist"}}`, []string{"premium", "cheap"}, false},
+		{"overloaded fails over", 503, `overloaded`, []string{"premium", "cheap"}, false},
+		{"bad request does not fail over", 400, `{"error":{"message":"bad prompt"}}`, []string{"premium"}, true},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				var (
+					mu   sync.Mutex
+					seen []string
+				)
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					body, _ := io.ReadAll(r.Body)
+					model, _ := decodeBody(t, body)["model"].(string)
+					mu.Lock()
+					seen = append(seen, model)
+					mu.Unlock()
+					if model == "premium" {
+						w.WriteHeader(tt.status)
+						io.WriteString(w, tt.body)
+						return
+					}
+					if stream {
+						w.Header().Set("Content-Type", "text/event-stream")
+						io.WriteString(w, "data: "+deltaEvent("from "+model)+"\n\ndata: [DONE]\n\n")
+						return
+					}
+					io.WriteString(w, `{"model":"`+model+`","choices":[{"message":{"content":"from `+model+`"}}]}`)
+				}))
+				defer srv.Close()
+
+				req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "premium", FallbackModels: []string{"cheap"}, Prompt: "hi"}
+				var (
+					content string
+					err     error
+				)
+				if stream {
+					var resp *StreamResponse
+					resp, err = NewClient().SendStream(context.Background(), req, func(StreamChunk) error { return nil })
+					if resp != nil {
+						content = resp.Content
+					}
+				} else {
+					var resp *Response
+					resp, err = NewClient().Send(context.Background(), req)
+					if resp != nil {
+						content = resp.Content
+					}
+				}
+
+				if !reflect.DeepEqual(seen, tt.wantModels) {
+					t.Errorf("models tried = %v, want %v", seen, tt.wantModels)
+				}
+				if tt.wantErr {
+					if _, ok := AsAPIError(err); !ok {
+						t.Errorf("err = %v, want the APIError of the first model", err)
+					}
+					return
+				}
+				if err != nil || content != "from cheap" {
+					t.Errorf("got %q, %v; want the fallback answer", content, err)
+				}
+			})
+		}
+	}
+}
+
+func TestLocaleAcceptLanguage(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Get("Accept-Language"))
+		mu.Unlock()
+		if r.URL.Path == "/api/chat" {
+			io.WriteString(w, `{"model":"m","message":{"role":"assistant","content":"ok"},"done":true}`)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		provider string
+		endpoint string
+	}{
+		{srv.URL + "/v1/chat/completions", ""},
+		{srv.URL + "/api/chat", ""},
+		{"openai", srv.URL + "/v1/chat/completions"},
+		{"openrouter", srv.URL + "/v1/chat/completions"},
+		{"anthropic", srv.URL + "/v1/messages"},
+		{"groq", srv.URL + "/v1/chat/completions"},
+		{"mistral", srv.URL + "/v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		for _, locale := range []string{"de-DE", ""} {
+			t.Run(tt.provider+"/"+locale, func(t *testing.T) {
+				mu.Lock()
+				got = nil
+				mu.Unlock()
+				req := &Request{Provider: tt.provider, Endpoint: tt.endpoint, Model: "m", Prompt: "hi"}
+				WithLocale(locale)(req)
+				if _, err := NewClient().Send(context.Background(), req); err != nil {
+					t.Fatalf("Send: %v", err)
+				}
+				if len(got) != 1 || got[0] != locale {
+					t.Errorf("Accept-Language = %q, want [%q]", got, locale)
+				}
+			})
+		}
+	}
+}
+
+func TestRawChat(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		payload map[string]interface{}
+		auth    string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		payload, auth = decodeBody(t, body), r.Header.Get("Authorization")
+		mu.Unlock()
+		if payload["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+deltaEvent("Hel")+"\n\ndata: "+deltaEvent("lo")+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"id":"raw-1","custom":true}`)
+	}))
+	defer srv.Close()
+
+	body := map[string]any{"model": "m", "input": "hi", "vendor_flag": 3.0}
+	c := NewClient()
+
+	raw, err := c.RawChat(context.Background(), srv.URL+"/v9/generate", body, "sk")
+	if err != nil {
+		t.Fatalf("RawChat: %v", err)
+	}
+	if string(raw) != `{"id":"raw-1","custom":true}` {
+		t.Errorf("RawChat body = %s", raw)
+	}
+	if !reflect.DeepEqual(payload, map[string]interface{}(body)) || auth != "Bearer sk" {
+		t.Errorf("server got %v with auth %q, want %v", payload, auth, body)
+	}
+
+	var content string
+	err = c.RawChatStream(context.Background(), srv.URL+"/v9/generate", body, "sk", func(chunk StreamChunk) error {
+		content += chunk.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RawChatStream: %v", err)
+	}
+	if content != "Hello" {
+		t.Errorf("streamed content = %q, want Hello", content)
+	}
+	if payload["stream"] != true || payload["vendor_flag"] != 3.0 {
+		t.Errorf("stream payload = %v", payload)
+	}
+	if _, ok := body["stream"]; ok {
+		t.Error("RawChatStream modified the caller's payload")
+	}
+
+	if _, err := c.RawChat(context.Background(), "not a url", body, ""); err == nil {
+		t.Error("RawChat accepted an invalid url")
+	}
+}
+
+// sentMessages returns the messages of a recorded chat payload.
+func sentMessages(payload map[string]interface{}) []map[string]interface{} {
+	raw, _ := payload["messages"].([]interface{})
+	msgs := make([]map[string]interface{}, len(raw))
+	for i, m := range raw {
+		msgs[i], _ = m.(map[string]interface{})
+	}
+	return msgs
+}
+
+func TestDefaultSystemPrompt(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	tests := []struct {
+		name       string
+		opts       []ClientOption
+		system     string
+		wantSystem string
+	}{
+		{"default applied", []ClientOption{WithSystemPrompt("house rules")}, "", "house rules"},
+		{"request overrides", []ClientOption{WithSystemPrompt("house rules")}, "mine", "mine"},
+		{"no default", nil, "", ""},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				c := NewClient(tt.opts...)
+				req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", SystemPrompt: tt.system, Prompt: "hi"}
+				var err error
+				if stream {
+					_, _, err = collectStream(t, c, req)
+				} else {
+					_, err = c.Send(context.Background(), req)
+				}
+				if err != nil {
+					t.Fatalf("request: %v", err)
+				}
+				var system string
+				for _, m := range sentMessages(lastPayload()) {
+					if m["role"] == "system" {
+						system, _ = m["content"].(string)
+					}
+				}
+				if system != tt.wantSystem {
+					t.Errorf("system prompt = %q, want %q", system, tt.wantSystem)
+				}
+				if req.SystemPrompt != tt.system {
+					t.Errorf("caller's request changed to %q", req.SystemPrompt)
+				}
+			})
+		}
+	}
+}
+
+func TestServedModel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if decodeBody(t, body)["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, `data: {"model":"anthropic/claude-3.5-sonnet","choices":[{"delta":{"content":"o"}}]}`+"\n\n")
+			io.WriteString(w, `data: {"model":"anthropic/claude-3.5-sonnet","choices":[{"delta":{"content":"k"}}]}`+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"model":"anthropic/claude-3.5-sonnet","choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	const served = "anthropic/claude-3.5-sonnet"
+	req := &Request{Provider: "openrouter", Endpoint: srv.URL + "/v1/chat/completions", Model: "openrouter/auto", Prompt: "hi"}
+	resp, err := NewClient().Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if resp.Model != served {
+		t.Errorf("Response.Model = %q, want %q", resp.Model, served)
+	}
+
+	chunks, streamResp, err := collectStream(t, NewClient(), req)
+	if err != nil {
+		t.Fatalf("SendStream: %v", err)
+	}
+	if streamResp.Model != served {
+		t.Errorf("StreamResponse.Model = %q, want %q", streamResp.Model, served)
+	}
+	if chunks[0].Model != served {
+		t.Errorf("first chunk model = %q, want %q", chunks[0].Model, served)
+	}
+}
+
+// roles renders sent messages as "role:content" for order assertions.
+func roles(msgs []map[string]interface{}) []string {
+	out := make([]string, len(msgs))
+	for i, m := range msgs {
+		content, _ := m["content"].(string)
+		out[i] = m["role"].(string) + ":" + content
+	}
+	return out
+}
+
+func TestFewShotPlacement(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	c := NewClient(WithFewShot([]Message{NewUserMessage("2+2?"), NewAssistantMessage("4")}))
+
+	tests := []struct {
+		name     string
+		system   string
+		messages []Message
+		want     []string
+	}{
+		{"after system, before the user turn", "Answer briefly", []Message{NewUserMessage("3+3?")},
+			[]string{"system:Answer briefly", "user:2+2?", "assistant:4", "user:3+3?"}},
+		{"without a system prompt", "", []Message{NewUserMessage("3+3?")},
+			[]string{"user:2+2?", "assistant:4", "user:3+3?"}},
+		{"once before a longer session", "Answer briefly", []Message{NewUserMessage("3+3?"), NewAssistantMessage("6"), NewUserMessage("4+4?")},
+			[]string{"system:Answer briefly", "user:2+2?", "assistant:4", "user:3+3?", "assistant:6", "user:4+4?"}},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", SystemPrompt: tt.system, Messages: tt.messages}
+				var err error
+				if stream {
+					_, _, err = collectStream(t, c, req)
+				} else {
+					_, err = c.Send(context.Background(), req)
+				}
+				if err != nil {
+					t.Fatalf("request: %v", err)
+				}
+				if got := roles(sentMessages(lastPayload())); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("messages = %q, want %q", got, tt.want)
+				}
+				if len(req.Messages) != len(tt.messages) {
+					t.Errorf("caller's messages grew to %d", len(req.Messages))
+				}
+			})
+		}
+	}
+}
+
+func TestContextInjectionOrder(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	fewShot := WithFewShot([]Message{NewUserMessage("2+2?"), NewAssistantMessage("4")})
+
+	tests := []struct {
+		name  string
+		order []InjectionSlot
+		want  []string
+	}{
+		{"default", nil,
+			[]string{"system:Be brief", "user:2+2?", "assistant:4", "user:Context:\n\ndoc\n\n3+3?", "assistant:6", "user:4+4?"}},
+		{"context before examples", []InjectionSlot{InjectSystem, InjectContext, InjectFewShot, InjectHistory},
+			[]string{"system:Be brief\n\nContext:\n\ndoc", "user:2+2?", "assistant:4", "user:3+3?", "assistant:6", "user:4+4?"}},
+		{"context first", []InjectionSlot{InjectContext, InjectSystem},
+			[]string{"system:Context:\n\ndoc\n\nBe brief", "user:2+2?", "assistant:4", "user:3+3?", "assistant:6", "user:4+4?"}},
+		{"system after examples", []InjectionSlot{InjectFewShot, InjectSystem},
+			[]string{"user:2+2?", "assistant:4", "user:Be brief\n\nContext:\n\ndoc\n\n3+3?", "assistant:6", "user:4+4?"}},
+		{"history first", []InjectionSlot{InjectHistory},
+			[]string{"user:Be brief\n\nContext:\n\ndoc\n\n3+3?", "assistant:6", "user:4+4?", "user:2+2?", "assistant:4"}},
+		{"context after history", []InjectionSlot{InjectSystem, InjectFewShot, InjectHistory, InjectContext},
+			[]string{"system:Be brief", "user:2+2?", "assistant:4", "user:Context:\n\ndoc\n\n3+3?", "assistant:6", "user:4+4?"}},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				opts := []ClientOption{fewShot}
+				if tt.order != nil {
+					opts = append(opts, WithContextInjectionOrder(tt.order...))
+				}
+				c := NewClient(opts...)
+				messages := []Message{NewUserMessage("3+3?"), NewAssistantMessage("6"), NewUserMessage("4+4?")}
+				req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", SystemPrompt: "Be brief", Messages: messages, RetrievedContext: []string{"doc"}}
+				var err error
+				if stream {
+					_, _, err = collectStream(t, c, req)
+				} else {
+					_, err = c.Send(context.Background(), req)
+				}
+				if err != nil {
+					t.Fatalf("request: %v", err)
+				}
+				if got := roles(sentMessages(lastPayload())); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("messages = %q, want %q", got, tt.want)
+				}
+				if messages[0].Content != "3+3?" || req.SystemPrompt != "Be brief" {
+					t.Error("the caller's request was modified")
+				}
+			})
+		}
+	}
+}
+
+func TestModelAliases(t *testing.T) {
+	c := NewClient(WithModelAliases(map[string]string{"Fast": "openai-fast", "smart": "openai-large"}))
+	tests := []struct {
+		model, want string
+	}{
+		{"fast", "openai-fast"},
+		{"FAST", "openai-fast"},
+		{"smart", "openai-large"},
+		{"mistral", "mistral"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := c.resolveModel(tt.model); got != tt.want {
+			t.Errorf("resolveModel(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+
+	srv, lastPayload := samplingServer(t)
+	for _, tt := range []struct{ model, want string }{{"Fast", "openai-fast"}, {"unknown-model", "unknown-model"}} {
+		req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: tt.model, Prompt: "hi"}
+		if _, err := c.Send(context.Background(), req); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if got := lastPayload()["model"]; got != tt.want {
+			t.Errorf("model %q sent as %v, want %q", tt.model, got, tt.want)
+		}
+		if req.Model != tt.model {
+			t.Errorf("req.Model changed to %q", req.Model)
+		}
+	}
+}
+
+func TestProviderDefaultModel(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	c := NewClient(
+		WithProviderDefaults(map[string]string{" OpenRouter ": "fast", "ollama": "llama3"}),
+		WithModelAliases(map[string]string{"fast": "openai/gpt-4o-mini"}),
+	)
+	tests := []struct {
+		name, provider, model, want string
+	}{
+		{"default through alias", "openrouter", "", "openai/gpt-4o-mini"},
+		{"provider name case", "OLLAMA", "", "llama3"},
+		{"explicit model wins", "ollama", "qwen2", "qwen2"},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			req := &Request{Provider: tt.provider, Endpoint: srv.URL + "/v1/chat/completions", Model: tt.model, Prompt: "hi"}
+			var err error
+			if stream {
+				_, _, err = collectStream(t, c, req)
+			} else {
+				_, err = c.Send(context.Background(), req)
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if got := lastPayload()["model"]; got != tt.want {
+				t.Errorf("%s (stream %v): model = %v, want %q", tt.name, stream, got, tt.want)
+			}
+			if req.Model != tt.model {
+				t.Errorf("%s: req.Model changed to %q", tt.name, req.Model)
+			}
+		}
+	}
+}
+
+func TestMultipartResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"model":"m","choices":[{"finish_reason":"stop","message":{"role":"assistant","content":[
+			{"type":"text","text":"Here is the cat: "},
+			{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}},
+			{"type":"text","text":"enjoy!"}
+		]}}]}`)
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient().Send(context.Background(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "draw a cat"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	want := []ContentPart{
+		NewTextPart("Here is the cat: "),
+		NewImageURLPart("data:image/png;base64,iVBORw0KGgo="),
+		NewTextPart("enjoy!"),
+	}
+	if !reflect.DeepEqual(resp.ContentParts, want) {
+		t.Errorf("parts = %+v, want %+v", resp.ContentParts, want)
+	}
+	if resp.Content != "Here is the cat: enjoy!" {
+		t.Errorf("content = %q", resp.Content)
+	}
+	if resp.FinishReason != "stop" || resp.Model != "m" {
+		t.Errorf("finish reason = %q, model = %q", resp.FinishReason, resp.Model)
+	}
+}
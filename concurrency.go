@@ -0,0 +1,34 @@
+package llmclient
+
+import (
+	"context"
+	"strings"
+)
+
+// WithMaxConcurrent bounds the calls in flight for one provider across chat,
+// streaming and every sub-API. Further calls wait for a free slot or until
+// their context is done. A stream holds its slot until it ends.
+func WithMaxConcurrent(provider string, n int) ClientOption {
+	return func(c *Client) {
+		if n < 1 {
+			return
+		}
+		if c.providerSlots == nil {
+			c.providerSlots = make(map[string]chan struct{})
+		}
+		c.providerSlots[strings.ToLower(strings.TrimSpace(provider))] = make(chan struct{}, n)
+	}
+}
+
+func (c *Client) acquireProvider(ctx context.Context, provider string) (release func(), err error) {
+	slots := c.providerSlots[strings.ToLower(strings.TrimSpace(provider))]
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,71 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrent(t *testing.T) {
+	var inFlight, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithMaxConcurrent(" OpenRouter ", 2))
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Send(context.Background(), &Request{Provider: "openrouter", Endpoint: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(&peak); got != 2 {
+		t.Errorf("peak requests in flight = %d, want 2", got)
+	}
+}
+
+func TestMaxConcurrentWaitHonoursContext(t *testing.T) {
+	c := NewClient(WithMaxConcurrent("openrouter", 1))
+	hold, err := c.acquireProvider(context.Background(), "openrouter")
+	if err != nil {
+		t.Fatalf("acquireProvider: %v", err)
+	}
+	defer hold()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = c.Send(ctx, &Request{Provider: "openrouter", Endpoint: "http://127.0.0.1:1/v1/chat/completions", Model: "m", Prompt: "hi"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the wait to end with the context", err)
+	}
+	if _, err := c.acquireProvider(context.Background(), "ollama"); err != nil {
+		t.Errorf("an uncapped provider waited: %v", err)
+	}
+}
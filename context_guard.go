@@ -0,0 +1,61 @@
+package llmclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrContextExceeded = errors.New("prompt exceeds model context window")
+
+// messageOverheadTokens approximates the role and separator tokens that chat
+// templates add around every message.
+const messageOverheadTokens = 4
+
+// WithContextGuard makes Send and SendStream fail with ErrContextExceeded
+// before any network call when the estimated prompt is larger than the
+// model's ContextWindow in models. Models that are not listed, or have no
+// window, are not checked.
+func WithContextGuard(models []Model) ClientOption {
+	return func(c *Client) { c.contextModels = models }
+}
+
+// WithTokenEstimator replaces EstimateTokens in the context guard, e.g. with
+// a real tokenizer.
+func WithTokenEstimator(estimate TokenEstimator) ClientOption {
+	return func(c *Client) { c.tokenEstimator = estimate }
+}
+
+// CheckFits reports ErrContextExceeded when messages clearly do not fit the
+// context window of model, as listed in WithContextGuard.
+func (c *Client) CheckFits(model string, messages []Message) error {
+	return c.checkFits(model, "", messages)
+}
+
+func (c *Client) checkFits(model, systemPrompt string, messages []Message) error {
+	m, ok := FindModel(c.contextModels, model)
+	if !ok || m.ContextWindow <= 0 {
+		return nil
+	}
+	if tokens := c.estimatePromptTokens(systemPrompt, messages); tokens > m.ContextWindow {
+		return fmt.Errorf("%w: about %d tokens, %s allows %d", ErrContextExceeded, tokens, m.Name, m.ContextWindow)
+	}
+	return nil
+}
+
+func (c *Client) estimateTokens(text string) int {
+	if c.tokenEstimator != nil {
+		return c.tokenEstimator(text)
+	}
+	return EstimateTokens(text)
+}
+
+func (c *Client) estimatePromptTokens(systemPrompt string, messages []Message) int {
+	tokens := 0
+	if systemPrompt != "" {
+		tokens += c.estimateTokens(systemPrompt) + messageOverheadTokens
+	}
+	for _, msg := range messages {
+		tokens += c.estimateTokens(messageText(msg)) + messageOverheadTokens
+	}
+	return tokens
+}
@@ -0,0 +1,76 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestContextGuard(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		body, _ := io.ReadAll(r.Body)
+		if stream, _ := decodeBody(t, body)["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+deltaEvent("ok")+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	words := func(text string) int { return len(strings.Fields(text)) }
+	c := NewClient(
+		WithContextGuard([]Model{{Name: "small", ContextWindow: 20}, {Name: "unbounded"}}),
+		WithTokenEstimator(words),
+	)
+	prompt := func(n int) string { return strings.TrimSpace(strings.Repeat("word ", n)) }
+	tests := []struct {
+		name         string
+		model        string
+		systemPrompt string
+		prompt       string
+		wantErr      bool
+	}{
+		// Each message costs its words plus messageOverheadTokens.
+		{"fits", "small", "", prompt(16), false},
+		{"oversized prompt", "small", "", prompt(17), true},
+		{"system prompt counts", "small", prompt(4), prompt(10), true},
+		{"unlisted model", "large", "", prompt(500), false},
+		{"model without window", "unbounded", "", prompt(500), false},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			atomic.StoreInt32(&hits, 0)
+			req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: tt.model, SystemPrompt: tt.systemPrompt, Prompt: tt.prompt}
+			var err error
+			if stream {
+				_, _, err = collectStream(t, c, req)
+			} else {
+				_, err = c.Send(context.Background(), req)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrContextExceeded) {
+					t.Errorf("%s (stream %v): err = %v, want ErrContextExceeded", tt.name, stream, err)
+				}
+				if n := atomic.LoadInt32(&hits); n != 0 {
+					t.Errorf("%s (stream %v): %d HTTP calls, want none", tt.name, stream, n)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s (stream %v): %v", tt.name, stream, err)
+			}
+		}
+	}
+
+	if err := c.CheckFits("small", []Message{NewUserMessage(prompt(30))}); !errors.Is(err, ErrContextExceeded) {
+		t.Errorf("CheckFits = %v, want ErrContextExceeded", err)
+	}
+}
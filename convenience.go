@@ -2,7 +2,11 @@ package llmclient
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -86,10 +90,45 @@ func WithEndpoint(endpoint string) SendOption {
 	return func(r *Request) { r.Endpoint = endpoint }
 }
 
+func WithOpenRouterTransforms(transforms ...string) SendOption {
+	return func(r *Request) { r.OpenRouterTransforms = transforms }
+}
+
+// WithWebSearch turns on the provider's built-in web search: the ":online"
+// model suffix on OpenRouter and web_search_options on OpenAI. Other
+// providers ignore it.
+func WithWebSearch() SendOption {
+	return func(r *Request) { r.WebSearch = true }
+}
+
+// WithPromptCacheTTL asks providers with configurable prompt caching to keep
+// the prompt prefix for ttl ("5m" or "1h" for Anthropic). Other providers
+// ignore it.
+func WithPromptCacheTTL(ttl string) SendOption {
+	return func(r *Request) { r.PromptCacheTTL = ttl }
+}
+
+// WithSafePrompt sets Mistral's safe_prompt; other providers ignore it.
+func WithSafePrompt(enabled bool) SendOption {
+	return func(r *Request) { r.SafePrompt = &enabled }
+}
+
 func WithTemperature(temp float64) SendOption {
 	return func(r *Request) { r.Temperature = &temp }
 }
 
+func WithTopP(topP float64) SendOption {
+	return func(r *Request) { r.TopP = &topP }
+}
+
+func WithFrequencyPenalty(penalty float64) SendOption {
+	return func(r *Request) { r.FrequencyPenalty = &penalty }
+}
+
+func WithPresencePenalty(penalty float64) SendOption {
+	return func(r *Request) { r.PresencePenalty = &penalty }
+}
+
 func WithMaxTokens(max int) SendOption {
 	return func(r *Request) { r.MaxTokens = &max }
 }
@@ -98,6 +137,58 @@ func WithSeed(seed int) SendOption {
 	return func(r *Request) { r.Seed = &seed }
 }
 
+// WithLocale sets the Accept-Language header. It is a header-only hint: none
+// of the supported chat APIs has a payload field for the answer language, so
+// say it in the prompt when it must be enforced.
+func WithLocale(locale string) SendOption {
+	return func(r *Request) { r.Locale = locale }
+}
+
+func WithCompletion() SendOption {
+	return func(r *Request) { r.Completion = true }
+}
+
+func WithStreamBuffer(n int) SendOption {
+	return func(r *Request) { r.StreamBuffer = n }
+}
+
+// WithTools lists OpenAI-style function definitions, e.g. GenerateImageTool(),
+// that the model may call. The calls come back in Response.ToolCalls.
+func WithTools(tools ...map[string]interface{}) SendOption {
+	return func(r *Request) { r.Tools = tools }
+}
+
+// WithRetrievedContext adds retrieved documents (RAG) as one block of system
+// text; its position is set by WithContextInjectionOrder.
+func WithRetrievedContext(docs ...string) SendOption {
+	return func(r *Request) { r.RetrievedContext = docs }
+}
+
+// WithStore asks OpenAI to keep the completion for later retrieval and evals;
+// WithMetadata tags the stored completion. Both are sent to OpenAI and
+// OpenRouter only.
+func WithStore(store bool) SendOption {
+	return func(r *Request) { r.Store = &store }
+}
+
+func WithMetadata(metadata map[string]string) SendOption {
+	return func(r *Request) { r.Metadata = metadata }
+}
+
+func WithStop(seqs ...string) SendOption {
+	return func(r *Request) { r.Stop = seqs }
+}
+
+// WithStreamUsage asks OpenAI-compatible providers for a final usage chunk;
+// the totals arrive on the Done chunk and in StreamResponse.Usage.
+func WithStreamUsage() SendOption {
+	return func(r *Request) { r.StreamUsage = true }
+}
+
+func WithClientSideStop() SendOption {
+	return func(r *Request) { r.ClientSideStop = true }
+}
+
 func WithTimeout(timeout time.Duration) ClientOption {
 	return func(c *Client) {
 		c.httpClient = &http.Client{Timeout: timeout}
@@ -126,6 +217,29 @@ func GenerateImageWithContext(ctx context.Context, provider, model, apiKey, prom
 	return resp.Data, nil
 }
 
+func GenerateImageURL(provider, model, apiKey, prompt string, opts ...ImageOption) (string, error) {
+	return GenerateImageURLWithContext(context.Background(), provider, model, apiKey, prompt, opts...)
+}
+
+func GenerateImageURLWithContext(ctx context.Context, provider, model, apiKey, prompt string, opts ...ImageOption) (string, error) {
+	req := &ImageRequest{
+		Provider: provider,
+		Model:    model,
+		APIKey:   apiKey,
+		Prompt:   prompt,
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+	req.ResponseFormat = ImageResponseURL
+	client := NewClient()
+	resp, err := client.GenerateImage(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return resp.URL, nil
+}
+
 type ImageOption func(*ImageRequest)
 
 func WithImageWidth(width int) ImageOption {
@@ -140,6 +254,28 @@ func WithImageSeed(seed int) ImageOption {
 	return func(r *ImageRequest) { r.Seed = &seed }
 }
 
+func WithImageQuality(quality string) ImageOption {
+	return func(r *ImageRequest) { r.Quality = quality }
+}
+
+func WithImageStyle(style string) ImageOption {
+	return func(r *ImageRequest) { r.Style = style }
+}
+
+func WithImageProgress(fn func(downloaded, total int64)) ImageOption {
+	return func(r *ImageRequest) { r.Progress = fn }
+}
+
+func WithImageResponseFormat(format ImageResponseFormat) ImageOption {
+	return func(r *ImageRequest) { r.ResponseFormat = format }
+}
+
+// WithImageEndpoint sends the request to an OpenAI-compatible images endpoint,
+// such as a self-hosted Stable Diffusion or ComfyUI server.
+func WithImageEndpoint(endpoint string) ImageOption {
+	return func(r *ImageRequest) { r.Endpoint = endpoint }
+}
+
 func NewUserMessage(text string) Message {
 	return Message{Role: "user", Content: text}
 }
@@ -148,6 +284,12 @@ func NewAssistantMessage(text string) Message {
 	return Message{Role: "assistant", Content: text}
 }
 
+// NewAssistantToolCallsMessage records the model's tool calls in the history;
+// each must be followed by a tool message answering it.
+func NewAssistantToolCallsMessage(text string, calls []ToolCall) Message {
+	return Message{Role: "assistant", Content: text, ToolCalls: calls}
+}
+
 func NewSystemMessage(text string) Message {
 	return Message{Role: "system", Content: text}
 }
@@ -160,6 +302,38 @@ func NewUserMessageWithImages(text string, imageUrls []string) Message {
 	return Message{Role: "user", Content: text, ContentParts: parts}
 }
 
+func NewImagePartFromFile(path string) (ContentPart, error) {
+	dataURL, err := imageFileDataURL(path)
+	if err != nil {
+		return ContentPart{}, err
+	}
+	return NewImageURLPart(dataURL), nil
+}
+
+func ImagesFromFiles(paths ...string) ([]string, error) {
+	images := make([]string, 0, len(paths))
+	for _, path := range paths {
+		dataURL, err := imageFileDataURL(path)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, dataURL)
+	}
+	return images, nil
+}
+
+func imageFileDataURL(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read image: %w", err)
+	}
+	mediaType := http.DetectContentType(data)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return "", fmt.Errorf("unsupported image type %s: %s", mediaType, path)
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
 func NewUserMessageWithContentParts(parts []ContentPart) Message {
 	var textContent string
 	for _, p := range parts {
@@ -171,6 +345,22 @@ func NewUserMessageWithContentParts(parts []ContentPart) Message {
 	return Message{Role: "user", ContentParts: parts, Content: textContent}
 }
 
+func NewToolMessage(toolCallID, text string) Message {
+	return Message{Role: "tool", Content: text, ToolCallID: toolCallID}
+}
+
+func NewToolMessageWithContentParts(toolCallID string, parts []ContentPart) Message {
+	msg := NewMessageWithContentParts("tool", parts)
+	msg.ToolCallID = toolCallID
+	return msg
+}
+
+func NewMessageWithContentParts(role string, parts []ContentPart) Message {
+	msg := NewUserMessageWithContentParts(parts)
+	msg.Role = role
+	return msg
+}
+
 func GenerateAudio(provider, apiKey, prompt string, opts ...AudioOption) ([]byte, error) {
 	return GenerateAudioWithContext(context.Background(), provider, apiKey, prompt, opts...)
 }
@@ -198,6 +388,10 @@ func WithAudioModel(model string) AudioOption {
 	return func(r *AudioRequest) { r.Model = model }
 }
 
+func WithAudioVoice(voice string) AudioOption {
+	return func(r *AudioRequest) { r.Voice = voice }
+}
+
 func SendStream(provider, model, apiKey, systemPrompt, prompt string, callback StreamCallback, opts ...SendOption) (string, error) {
 	return SendStreamWithContext(context.Background(), provider, model, apiKey, systemPrompt, prompt, callback, opts...)
 }
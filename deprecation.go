@@ -0,0 +1,47 @@
+package llmclient
+
+import (
+	"errors"
+	"strings"
+)
+
+var deprecationMarkers = []string{"deprecated", "decommissioned", "no longer supported", "no longer available", "has been retired"}
+
+// WithModelSubstitutionOnDeprecation maps deprecated model names to their
+// replacements. When a provider rejects a listed model as deprecated, the
+// request is retried once with the replacement and notify (if set) is called
+// so the substitution can be logged.
+func WithModelSubstitutionOnDeprecation(replacements map[string]string, notify func(deprecated, replacement string)) ClientOption {
+	return func(c *Client) {
+		c.deprecatedModels = make(map[string]string, len(replacements))
+		for deprecated, replacement := range replacements {
+			c.deprecatedModels[strings.ToLower(deprecated)] = replacement
+		}
+		c.onModelSubstitution = notify
+	}
+}
+
+func (c *Client) deprecationReplacement(model string, err error) (string, bool) {
+	replacement, ok := c.deprecatedModels[strings.ToLower(model)]
+	if !ok || replacement == "" || !isDeprecationError(err) {
+		return "", false
+	}
+	if c.onModelSubstitution != nil {
+		c.onModelSubstitution(model, replacement)
+	}
+	return replacement, true
+}
+
+func isDeprecationError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode < 400 || apiErr.StatusCode >= 500 {
+		return false
+	}
+	body := strings.ToLower(apiErr.Body)
+	for _, marker := range deprecationMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestModelSubstitutionOnDeprecation(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		models []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payload := decodeBody(t, body)
+		model, _ := payload["model"].(string)
+		mu.Lock()
+		models = append(models, model)
+		mu.Unlock()
+		switch model {
+		case "mixtral-8x7b-32768":
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"message":"The model mixtral-8x7b-32768 has been decommissioned and is no longer supported.","code":"model_decommissioned"}}`)
+			return
+		case "bad-request":
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"message":"messages: field required"}}`)
+			return
+		}
+		if stream, _ := payload["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+deltaEvent("ok")+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	var notified [][2]string
+	c := NewClient(WithModelSubstitutionOnDeprecation(map[string]string{
+		"Mixtral-8x7b-32768": "mistral-saba-24b",
+		"bad-request":        "other",
+	}, func(deprecated, replacement string) {
+		notified = append(notified, [2]string{deprecated, replacement})
+	}))
+
+	tests := []struct {
+		name       string
+		model      string
+		wantErr    bool
+		wantModels []string
+		wantNotify [][2]string
+	}{
+		{"deprecated model replaced", "mixtral-8x7b-32768", false,
+			[]string{"mixtral-8x7b-32768", "mistral-saba-24b"}, [][2]string{{"mixtral-8x7b-32768", "mistral-saba-24b"}}},
+		{"other 400 not retried", "bad-request", true, []string{"bad-request"}, nil},
+		{"unlisted model untouched", "llama3", false, []string{"llama3"}, nil},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			models, notified = nil, nil
+			req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: tt.model, Prompt: "hi"}
+			var err error
+			if stream {
+				_, _, err = collectStream(t, c, req)
+			} else {
+				_, err = c.Send(context.Background(), req)
+			}
+			var apiErr *APIError
+			if tt.wantErr != (err != nil) || (err != nil && !errors.As(err, &apiErr)) {
+				t.Errorf("%s (stream %v): err = %v", tt.name, stream, err)
+			}
+			if !reflect.DeepEqual(models, tt.wantModels) {
+				t.Errorf("%s (stream %v): models sent = %q, want %q", tt.name, stream, models, tt.wantModels)
+			}
+			if !reflect.DeepEqual(notified, tt.wantNotify) {
+				t.Errorf("%s (stream %v): notified = %q, want %q", tt.name, stream, notified, tt.wantNotify)
+			}
+		}
+	}
+}
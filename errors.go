@@ -0,0 +1,132 @@
+package llmclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrModelNotFound         = errors.New("model not found")
+	ErrUnexpectedContentType = errors.New("unexpected content type")
+	ErrEmptyContent          = errors.New("empty content in response")
+)
+
+const errorSnippetLen = 200
+
+func WithErrorOnEmptyContent() ClientOption {
+	return func(c *Client) { c.errorOnEmptyContent = true }
+}
+
+// APIError is returned when a provider answers with a non-2xx status.
+// Provider is the name from the request (e.g. "openrouter" or the URL).
+type APIError struct {
+	StatusCode int
+	Body       string
+	Provider   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error %d: %s", e.StatusCode, e.Body)
+}
+
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// withProvider records the provider name on an APIError coming from the
+// shared HTTP helpers, which only see the URL.
+func withProvider(err error, provider string) error {
+	if apiErr, ok := AsAPIError(err); ok && apiErr.Provider == "" {
+		apiErr.Provider = provider
+	}
+	return err
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == ErrModelNotFound && e.isModelNotFound()
+}
+
+func (e *APIError) isModelNotFound() bool {
+	code, message := parseErrorBody(e.Body)
+	if code == "model_not_found" {
+		return true
+	}
+	msg := strings.ToLower(message)
+	if !strings.Contains(msg, "model") {
+		return false
+	}
+	if strings.Contains(msg, "not found") || strings.Contains(msg, "does not exist") || strings.Contains(msg, "not a valid model") {
+		return true
+	}
+	return e.StatusCode == 404
+}
+
+// parseErrorBody extracts the error code and message from the common error
+// shapes: OpenAI {"error":{"code","message"}}, Ollama {"error":"..."} and
+// plain-text bodies.
+func parseErrorBody(body string) (code, message string) {
+	var r struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(body), &r); err != nil || len(r.Error) == 0 {
+		return "", body
+	}
+	var s string
+	if err := json.Unmarshal(r.Error, &s); err == nil {
+		return "", s
+	}
+	var obj struct {
+		Code    interface{} `json:"code"`
+		Type    string      `json:"type"`
+		Message string      `json:"message"`
+	}
+	if err := json.Unmarshal(r.Error, &obj); err != nil {
+		return "", body
+	}
+	if c, ok := obj.Code.(string); ok {
+		code = c
+	}
+	if code == "" {
+		code = obj.Type
+	}
+	return code, obj.Message
+}
+
+func isFailoverError(err error) bool {
+	if errors.Is(err, ErrModelNotFound) {
+		return true
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch {
+	case apiErr.StatusCode == 404, apiErr.StatusCode == 408, apiErr.StatusCode == 429:
+		return true
+	case apiErr.StatusCode >= 500:
+		return true
+	}
+	return false
+}
+
+// checkContentType catches captive portals and proxy error pages that answer
+// 200 with HTML where an API payload was expected.
+func checkContentType(resp *http.Response, body []byte) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" {
+		return nil
+	}
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > errorSnippetLen {
+		snippet = snippet[:errorSnippetLen] + "..."
+	}
+	return fmt.Errorf("%w %s: %s", ErrUnexpectedContentType, mediaType, snippet)
+}
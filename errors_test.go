@@ -0,0 +1,144 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorModelNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   bool
+	}{
+		{"openai code", 404, `{"error":{"message":"The model 'gpt-9' does not exist or you do not have access to it.","type":"invalid_request_error","param":null,"code":"model_not_found"}}`, true},
+		{"openai code on 400", 400, `{"error":{"message":"bad","code":"model_not_found"}}`, true},
+		{"ollama message", 404, `{"error":"model \"llama9\" not found, try pulling it first"}`, true},
+		{"openrouter message", 400, `{"error":{"message":"foo/bar is not a valid model ID","code":400}}`, true},
+		{"plain text 404", 404, `model not found`, true},
+		{"404 without model", 404, `404 page not found`, false},
+		{"invalid key", 401, `{"error":{"message":"Incorrect API key provided","code":"invalid_api_key"}}`, false},
+		{"model mentioned on 500", 500, `{"error":"model crashed"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := error(&APIError{StatusCode: tt.status, Body: tt.body})
+			if got := errors.Is(err, ErrModelNotFound); got != tt.want {
+				t.Errorf("errors.Is(ErrModelNotFound) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendClassifiesModelNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"openai", "/v1/chat/completions", `{"error":{"message":"The model 'x' does not exist","code":"model_not_found"}}`},
+		{"ollama", "/api/chat", `{"error":"model \"x\" not found, try pulling it first"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			_, err := NewClient().Send(context.Background(), &Request{Provider: srv.URL + tt.path, Model: "x", Prompt: "hi"})
+			if !errors.Is(err, ErrModelNotFound) {
+				t.Fatalf("err = %v, want ErrModelNotFound", err)
+			}
+			if apiErr, ok := AsAPIError(err); !ok || apiErr.StatusCode != http.StatusNotFound {
+				t.Errorf("err = %#v, want the underlying APIError", err)
+			}
+		})
+	}
+}
+
+func TestUnexpectedContentType(t *testing.T) {
+	const portal = "<html><body>Please log in to the hotel Wi-Fi</body></html>"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, portal)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithHTTPClient(rewriteClient(srv)))
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"chat", func() error {
+			_, err := c.Send(context.Background(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+			return err
+		}},
+		{"chat stream", func() error {
+			_, _, err := collectStream(t, c, &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+			return err
+		}},
+		{"image", func() error {
+			_, err := c.GenerateImage(context.Background(), &ImageRequest{Provider: "pollinations", Prompt: "a cat"})
+			return err
+		}},
+		{"audio", func() error {
+			_, err := c.GenerateAudio(context.Background(), &AudioRequest{Provider: "pollinations", Prompt: "hello"})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, ErrUnexpectedContentType) {
+				t.Fatalf("err = %v, want ErrUnexpectedContentType", err)
+			}
+			if !strings.Contains(err.Error(), "hotel Wi-Fi") {
+				t.Errorf("err = %v, want the page snippet", err)
+			}
+		})
+	}
+}
+
+func TestErrorOnEmptyContent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")
+		case "/tools":
+			io.WriteString(w, `{"choices":[{"message":{"content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]}}]}`)
+		default:
+			io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":""},"finish_reason":"stop"}]}`)
+		}
+	}))
+	defer srv.Close()
+
+	for _, option := range []bool{true, false} {
+		var opts []ClientOption
+		if option {
+			opts = append(opts, WithErrorOnEmptyContent())
+		}
+		c := NewClient(opts...)
+
+		_, err := c.Send(context.Background(), &Request{Provider: srv.URL + "/chat", Model: "m", Prompt: "hi"})
+		if got := errors.Is(err, ErrEmptyContent); got != option {
+			t.Errorf("option %v: Send err = %v", option, err)
+		}
+		_, _, err = collectStream(t, c, &Request{Provider: srv.URL + "/stream", Model: "m", Prompt: "hi"})
+		if got := errors.Is(err, ErrEmptyContent); got != option || (!option && err != nil) {
+			t.Errorf("option %v: SendStream err = %v", option, err)
+		}
+		// A reply that only calls tools is not empty.
+		if _, err := c.Send(context.Background(), &Request{Provider: srv.URL + "/tools", Model: "m", Prompt: "hi"}); err != nil {
+			t.Errorf("option %v: tool call reply: %v", option, err)
+		}
+	}
+}
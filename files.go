@@ -0,0 +1,159 @@
+package llmclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+const defaultOpenAIFilesURL = "https://api.openai.com/v1/files"
+
+// FileUploadRequest targets an OpenAI-compatible /files endpoint: "openai",
+// a URL in Provider, or Endpoint for any other name.
+type FileUploadRequest struct {
+	Provider string
+	APIKey   string
+	Endpoint string
+	FileName string
+	Data     []byte
+	MimeType string
+	Purpose  string
+}
+
+type FileUploadResponse struct {
+	FileID  string
+	Headers map[string]string
+	Raw     []byte
+}
+
+func (c *Client) UploadFile(ctx context.Context, req *FileUploadRequest) (*FileUploadResponse, error) {
+	if req == nil {
+		return nil, errors.New("file upload request is nil")
+	}
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	provider, err := c.newFileProvider(req)
+	if err != nil {
+		return nil, err
+	}
+
+	id, raw, err := provider.Upload(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.observeResponseBytes(req.Provider, len(raw))
+	return &FileUploadResponse{FileID: id, Headers: captured.headers(), Raw: raw}, nil
+}
+
+func (c *Client) newFileProvider(req *FileUploadRequest) (fileProvider, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Provider))
+
+	switch name {
+	case "openai":
+		endpoint := req.Endpoint
+		if endpoint == "" {
+			endpoint = defaultOpenAIFilesURL
+		}
+		return &openAIFileProvider{endpoint: endpoint, client: c.httpClient}, nil
+	default:
+		if isURL(name) {
+			return &openAIFileProvider{endpoint: req.Provider, client: c.httpClient}, nil
+		}
+		if isURL(req.Endpoint) {
+			return &openAIFileProvider{endpoint: req.Endpoint, client: c.httpClient}, nil
+		}
+		return nil, fmt.Errorf("unknown file provider: %s", req.Provider)
+	}
+}
+
+type fileProvider interface {
+	Upload(ctx context.Context, req *FileUploadRequest) (string, []byte, error)
+}
+
+type openAIFileProvider struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (p *openAIFileProvider) Upload(ctx context.Context, req *FileUploadRequest) (string, []byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	purpose := req.Purpose
+	if purpose == "" {
+		purpose = "user_data"
+	}
+	_ = writer.WriteField("purpose", purpose)
+
+	partHeader := make(textproto.MIMEHeader)
+	partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, filepath.Base(req.FileName)))
+	mimeType := req.MimeType
+	if mimeType == "" {
+		mimeType = http.DetectContentType(req.Data)
+	}
+	partHeader.Set("Content-Type", mimeType)
+	fileWriter, err := writer.CreatePart(partHeader)
+	if err != nil {
+		return "", nil, fmt.Errorf("create form file: %w", err)
+	}
+	if _, err := fileWriter.Write(req.Data); err != nil {
+		return "", nil, fmt.Errorf("write file data: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", nil, fmt.Errorf("close multipart writer: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint, &body)
+	if err != nil {
+		return "", nil, fmt.Errorf("create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+	if req.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", nil, fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return "", nil, &APIError{StatusCode: resp.StatusCode, Body: string(data), Provider: req.Provider}
+	}
+	if err := checkContentType(resp, data); err != nil {
+		return "", nil, err
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", nil, fmt.Errorf("parse response: %w", err)
+	}
+	if result.ID == "" {
+		return "", nil, errors.New("upload response has no file id")
+	}
+
+	return result.ID, data, nil
+}
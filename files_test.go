@@ -0,0 +1,140 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadFile(t *testing.T) {
+	pdf := []byte("%PDF-1.4 test document")
+	tests := []struct {
+		name        string
+		req         FileUploadRequest
+		status      int
+		body        string
+		wantID      string
+		wantPurpose string
+		wantMime    string
+		wantErr     bool
+	}{
+		{"defaults", FileUploadRequest{FileName: "dir/report.pdf", Data: pdf, APIKey: "sk"}, 200, `{"id":"file-123","object":"file"}`, "file-123", "user_data", "application/pdf", false},
+		{"explicit purpose and mime", FileUploadRequest{FileName: "a.jsonl", Data: []byte("{}"), Purpose: "batch", MimeType: "application/jsonl"}, 200, `{"id":"file-9"}`, "file-9", "batch", "application/jsonl", false},
+		{"api error", FileUploadRequest{FileName: "a.pdf", Data: pdf}, 413, `{"error":{"message":"too big"}}`, "", "", "", true},
+		{"missing id", FileUploadRequest{FileName: "a.pdf", Data: pdf}, 200, `{"object":"file"}`, "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				gotPurpose, gotMime, gotName, gotAuth string
+				gotData                               []byte
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/v1/files" {
+					t.Errorf("got %s %s, want POST /v1/files", r.Method, r.URL.Path)
+				}
+				gotAuth = r.Header.Get("Authorization")
+				gotPurpose = r.FormValue("purpose")
+				f, h, err := r.FormFile("file")
+				if err != nil {
+					t.Errorf("form file: %v", err)
+				} else {
+					gotName, gotMime = h.Filename, h.Header.Get("Content-Type")
+					gotData, _ = io.ReadAll(f)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			req := tt.req
+			req.Provider = "openai"
+			req.Endpoint = srv.URL + "/v1/files"
+			resp, err := NewClient().UploadFile(context.Background(), &req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("UploadFile = %+v, want an error", resp)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+			if resp.FileID != tt.wantID {
+				t.Errorf("FileID = %q, want %q", resp.FileID, tt.wantID)
+			}
+			if gotPurpose != tt.wantPurpose || gotMime != tt.wantMime {
+				t.Errorf("purpose, mime = %q, %q; want %q, %q", gotPurpose, gotMime, tt.wantPurpose, tt.wantMime)
+			}
+			if want := filepath.Base(tt.req.FileName); gotName != want {
+				t.Errorf("filename = %q, want %q", gotName, want)
+			}
+			if string(gotData) != string(tt.req.Data) {
+				t.Errorf("file data = %q, want %q", gotData, tt.req.Data)
+			}
+			wantAuth := ""
+			if tt.req.APIKey != "" {
+				wantAuth = "Bearer " + tt.req.APIKey
+			}
+			if gotAuth != wantAuth {
+				t.Errorf("Authorization = %q, want %q", gotAuth, wantAuth)
+			}
+		})
+	}
+}
+
+func TestUploadFileProviderRouting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"id":"file-1"}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		provider string
+		endpoint string
+		wantErr  bool
+	}{
+		{"url provider", srv.URL + "/v1/files", "", false},
+		{"named provider with endpoint", "gateway", srv.URL + "/v1/files", false},
+		{"unknown provider", "gemini", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient().UploadFile(context.Background(), &FileUploadRequest{Provider: tt.provider, Endpoint: tt.endpoint, FileName: "a.txt", Data: []byte("x")})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilePartReferencesUpload(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	msg := NewUserMessageWithContentParts([]ContentPart{NewTextPart("Summarize"), NewFilePart("file-123")})
+	if _, err := NewClient().Send(context.Background(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Messages: []Message{msg}}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var payload struct {
+		Messages []struct {
+			Content []map[string]json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || len(payload.Messages) != 1 || len(payload.Messages[0].Content) != 2 {
+		t.Fatalf("payload %s: %v", body, err)
+	}
+	if got := string(payload.Messages[0].Content[1]["file"]); got != `{"file_id":"file-123"}` {
+		t.Errorf("file part = %s, want {\"file_id\":\"file-123\"}", got)
+	}
+}
@@ -0,0 +1,34 @@
+package llmclient
+
+import (
+	"context"
+	"net/http"
+)
+
+const defaultGroqURL = "https://api.groq.com/openai/v1/chat/completions"
+
+// groqProvider talks to Groq's OpenAI-compatible endpoint. Structured output
+// uses json_object mode plus the schema instruction, which every Groq model
+// accepts.
+type groqProvider struct {
+	model    string
+	key      string
+	endpoint string
+	client   *http.Client
+	header   http.Header
+	chatOptions
+}
+
+func (p *groqProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	payload := p.newPayload(p.model, history, images, systemPrompt, false)
+	respBody, err := postJSON(ctx, p.client, p.endpoint, payload, p.key, p.header)
+	if err != nil {
+		return nil, err
+	}
+	return parseResponse(respBody)
+}
+
+func (p *groqProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
+	payload := p.newPayload(p.model, history, images, systemPrompt, true)
+	return postJSONStream(ctx, p.client, p.endpoint, payload, p.key, p.header, callback)
+}
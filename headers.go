@@ -0,0 +1,71 @@
+package llmclient
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// WithCaptureHeaders copies the named response headers (request IDs, cache
+// status and the like) into the Headers field of every response returned by
+// the client.
+func WithCaptureHeaders(names ...string) ClientOption {
+	return func(c *Client) {
+		c.captureHeaders = make([]string, 0, len(names))
+		for _, name := range names {
+			c.captureHeaders = append(c.captureHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+type headerCaptureKey struct{}
+
+// capturedHeaders collects headers for one client call. With retries or
+// failover only the last response is kept.
+type capturedHeaders struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (c *Client) startHeaderCapture(ctx context.Context) (context.Context, *capturedHeaders) {
+	if len(c.captureHeaders) == 0 {
+		return ctx, nil
+	}
+	captured := &capturedHeaders{}
+	return context.WithValue(ctx, headerCaptureKey{}, captured), captured
+}
+
+func (h *capturedHeaders) headers() map[string]string {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.values
+}
+
+type headerCaptureTransport struct {
+	base  http.RoundTripper
+	names []string
+}
+
+func (t *headerCaptureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	captured, ok := req.Context().Value(headerCaptureKey{}).(*capturedHeaders)
+	if !ok {
+		return resp, nil
+	}
+	values := make(map[string]string)
+	for _, name := range t.names {
+		if v := resp.Header.Get(name); v != "" {
+			values[name] = v
+		}
+	}
+	captured.mu.Lock()
+	captured.values = values
+	captured.mu.Unlock()
+	return resp, nil
+}
@@ -0,0 +1,94 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCaptureHeaders(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-Request-Id", "req-"+strconv.Itoa(int(n)))
+		w.Header().Set("X-Cache", "HIT")
+		w.Header().Set("X-Other", "ignored")
+		if r.URL.Path == "/retry" && n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/image/"):
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		case r.URL.Path == "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+deltaEvent("ok")+"\n\ndata: [DONE]\n\n")
+		default:
+			io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithHTTPClient(rewriteClient(srv)), WithCaptureHeaders("x-request-id", "X-CACHE"), WithRetry(2, time.Millisecond))
+	tests := []struct {
+		name string
+		run  func() (map[string]string, error)
+	}{
+		{"send", func() (map[string]string, error) {
+			resp, err := c.Send(context.Background(), &Request{Provider: srv.URL + "/chat", Model: "m", Prompt: "hi"})
+			if err != nil {
+				return nil, err
+			}
+			return resp.Headers, nil
+		}},
+		{"stream", func() (map[string]string, error) {
+			_, resp, err := collectStream(t, c, &Request{Provider: srv.URL + "/stream", Model: "m", Prompt: "hi"})
+			if err != nil {
+				return nil, err
+			}
+			return resp.Headers, nil
+		}},
+		{"image", func() (map[string]string, error) {
+			resp, err := c.GenerateImage(context.Background(), &ImageRequest{Provider: "pollinations", Prompt: "a cat"})
+			if err != nil {
+				return nil, err
+			}
+			return resp.Headers, nil
+		}},
+		// Only the response that succeeded is kept.
+		{"retried", func() (map[string]string, error) {
+			resp, err := c.Send(context.Background(), &Request{Provider: srv.URL + "/retry", Model: "m", Prompt: "hi"})
+			if err != nil {
+				return nil, err
+			}
+			return resp.Headers, nil
+		}},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&calls, 0)
+		got, err := tt.run()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		want := map[string]string{"X-Request-Id": "req-" + strconv.Itoa(int(atomic.LoadInt32(&calls))), "X-Cache": "HIT"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: headers = %v, want %v", tt.name, got, want)
+		}
+	}
+
+	resp, err := NewClient().Send(context.Background(), &Request{Provider: srv.URL + "/chat", Model: "m", Prompt: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Headers != nil {
+		t.Errorf("headers without WithCaptureHeaders = %v", resp.Headers)
+	}
+}
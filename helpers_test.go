@@ -0,0 +1,84 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// rewriteClient sends every request to srv, keeping the path, so the
+// hardcoded provider URLs can be served by a test server.
+func rewriteClient(srv *httptest.Server) *http.Client {
+	target, _ := url.Parse(srv.URL)
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		req.Host = ""
+		return srv.Client().Transport.RoundTrip(req)
+	})}
+}
+
+// cannedResponse builds the response a roundTripFunc returns.
+func cannedResponse(req *http.Request, status int, contentType, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+// decodeBody unmarshals a JSON request body captured by a test server.
+func decodeBody(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		t.Errorf("unmarshal body %q: %v", body, err)
+	}
+	return m
+}
+
+// sseServer answers every request with the given data events followed by
+// [DONE].
+func sseServer(t *testing.T, events ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// deltaEvent is an OpenAI chat.completion.chunk carrying content.
+func deltaEvent(content string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"content": content}}},
+	})
+	return string(data)
+}
+
+// collectStream runs SendStream and returns the delivered chunks.
+func collectStream(t *testing.T, c *Client, req *Request) ([]StreamChunk, *StreamResponse, error) {
+	t.Helper()
+	var chunks []StreamChunk
+	resp, err := c.SendStream(context.Background(), req, func(chunk StreamChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	return chunks, resp, err
+}
@@ -0,0 +1,98 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+const summarizeSystemPrompt = "Summarize the following conversation into a concise context note. " +
+	"Keep facts, decisions, names and open questions. Do not add commentary."
+
+func CountTurns(messages []Message) int {
+	turns := 0
+	for _, m := range messages {
+		if m.Role == "user" {
+			turns++
+		}
+	}
+	return turns
+}
+
+func WithMaxHistoryMessages(n int) ClientOption {
+	return func(c *Client) { c.maxHistoryMessages = n }
+}
+
+// trimHistory keeps every system message plus the last n other messages, in
+// their original order. A tool result whose assistant call was cut off is
+// dropped too, since providers reject it.
+func trimHistory(messages []Message, n int) []Message {
+	if n <= 0 {
+		return messages
+	}
+	start := len(messages)
+	for kept := 0; start > 0 && kept < n; start-- {
+		if messages[start-1].Role != "system" {
+			kept++
+		}
+	}
+	if start == 0 {
+		return messages
+	}
+	for start < len(messages) && messages[start].Role == "tool" {
+		start++
+	}
+
+	trimmed := make([]Message, 0, n+1)
+	for _, m := range messages[:start] {
+		if m.Role == "system" {
+			trimmed = append(trimmed, m)
+		}
+	}
+	return append(trimmed, messages[start:]...)
+}
+
+func SummarizeHistory(ctx context.Context, client *Client, messages []Message, summarizer *Request) (Message, error) {
+	if client == nil {
+		return Message{}, errors.New("client is nil")
+	}
+	if summarizer == nil {
+		return Message{}, errors.New("summarizer request is nil")
+	}
+	if len(messages) == 0 {
+		return Message{}, errors.New("no messages to summarize")
+	}
+
+	var transcript strings.Builder
+	for _, m := range messages {
+		transcript.WriteString(m.Role)
+		transcript.WriteString(": ")
+		transcript.WriteString(messageText(m))
+		transcript.WriteString("\n")
+	}
+
+	req := *summarizer
+	req.SystemPrompt = summarizeSystemPrompt
+	req.Messages = nil
+	req.Images = nil
+	req.Prompt = transcript.String()
+
+	resp, err := client.Send(ctx, &req)
+	if err != nil {
+		return Message{}, err
+	}
+	return NewSystemMessage("Summary of the earlier conversation:\n" + strings.TrimSpace(resp.Content)), nil
+}
+
+func messageText(m Message) string {
+	if m.Content != "" || len(m.ContentParts) == 0 {
+		return m.Content
+	}
+	var texts []string
+	for _, p := range m.ContentParts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
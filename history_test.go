@@ -0,0 +1,114 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeHistory(t *testing.T) {
+	var payload map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payload = decodeBody(t, body)
+		io.WriteString(w, `{"choices":[{"message":{"content":"  The user is Ann and likes tea.  "}}]}`)
+	}))
+	defer srv.Close()
+
+	history := []Message{
+		NewUserMessage("Hi, I'm Ann"),
+		NewAssistantMessage("Hello Ann"),
+		NewUserMessage("I like tea"),
+		NewAssistantMessage("Noted"),
+		NewUserMessage("What should I drink?"),
+		NewAssistantMessage("Tea, of course"),
+	}
+	older, recent := history[:4], history[4:]
+
+	summary, err := SummarizeHistory(context.Background(), NewClient(), older, &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m"})
+	if err != nil {
+		t.Fatalf("SummarizeHistory: %v", err)
+	}
+	compacted := append([]Message{summary}, recent...)
+
+	if len(compacted) != 3 {
+		t.Fatalf("compacted history has %d messages, want 3", len(compacted))
+	}
+	if summary.Role != "system" || summary.Content != "Summary of the earlier conversation:\nThe user is Ann and likes tea." {
+		t.Errorf("summary = %+v", summary)
+	}
+	if compacted[1].Content != recent[0].Content || compacted[2].Content != recent[1].Content {
+		t.Errorf("recent turns not kept: %+v", compacted[1:])
+	}
+
+	msgs, _ := payload["messages"].([]interface{})
+	if len(msgs) != 2 {
+		t.Fatalf("summarizer got %d messages, want system prompt and transcript", len(msgs))
+	}
+	transcript, _ := msgs[1].(map[string]interface{})["content"].(string)
+	for _, m := range older {
+		if !strings.Contains(transcript, m.Role+": "+m.Content) {
+			t.Errorf("transcript %q lacks %q", transcript, m.Content)
+		}
+	}
+	for _, m := range recent {
+		if strings.Contains(transcript, m.Content) {
+			t.Errorf("transcript %q includes the recent turn %q", transcript, m.Content)
+		}
+	}
+}
+
+func TestTrimHistory(t *testing.T) {
+	sys := NewSystemMessage("Be brief.")
+	u1, a1 := NewUserMessage("u1"), NewAssistantMessage("a1")
+	u2, a2 := NewUserMessage("u2"), NewAssistantMessage("a2")
+	u3 := NewUserMessage("u3")
+	call := NewAssistantToolCallsMessage("", []ToolCall{{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "f"}}})
+	result := NewToolMessage("call_1", "42")
+
+	tests := []struct {
+		name     string
+		messages []Message
+		n        int
+		want     []Message
+	}{
+		{"drops oldest turns", []Message{u1, a1, u2, a2, u3}, 3, []Message{u2, a2, u3}},
+		{"keeps system prompt", []Message{sys, u1, a1, u2, a2, u3}, 2, []Message{sys, a2, u3}},
+		{"keeps later system messages in place", []Message{u1, a1, sys, u2, a2, u3}, 2, []Message{sys, a2, u3}},
+		{"short history unchanged", []Message{sys, u1, a1}, 5, []Message{sys, u1, a1}},
+		{"no limit", []Message{u1, a1, u2}, 0, []Message{u1, a1, u2}},
+		{"orphaned tool result dropped", []Message{u1, call, result, a2, u3}, 3, []Message{a2, u3}},
+		{"tool call kept with its result", []Message{u1, call, result, a2, u3}, 4, []Message{call, result, a2, u3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimHistory(tt.messages, tt.n); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxHistoryMessages(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	c := NewClient(WithMaxHistoryMessages(2))
+	history := []Message{
+		NewUserMessage("u1"), NewAssistantMessage("a1"),
+		NewUserMessage("u2"), NewAssistantMessage("a2"),
+		NewUserMessage("u3"),
+	}
+	req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", SystemPrompt: "Be brief.", Messages: history}
+	if _, err := c.Send(context.Background(), req); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got, want := roles(sentMessages(lastPayload())), []string{"system:Be brief.", "assistant:a2", "user:u3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if len(req.Messages) != 5 {
+		t.Errorf("req.Messages trimmed in place to %d", len(req.Messages))
+	}
+}
@@ -2,44 +2,192 @@ package llmclient
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+const defaultOpenAIImagesURL = "https://api.openai.com/v1/images/generations"
+
+type ImageResponseFormat string
+
+const (
+	ImageResponseBytes ImageResponseFormat = "bytes"
+	ImageResponseURL   ImageResponseFormat = "url"
+)
+
+// ImageRequest.Endpoint points the "openai" provider, or any other name, at
+// an OpenAI-compatible /v1/images/generations URL; a URL in Provider works
+// the same way.
 type ImageRequest struct {
-	Provider string
-	Model    string
-	APIKey   string
-	Prompt   string
-	Width    *int
-	Height   *int
-	Seed     *int
+	Provider       string
+	Model          string
+	APIKey         string
+	Endpoint       string
+	Prompt         string
+	Width          *int
+	Height         *int
+	Seed           *int
+	Quality        string
+	Style          string
+	ResponseFormat ImageResponseFormat
+	Progress       func(downloaded, total int64)
 }
 
 type ImageResponse struct {
-	Data []byte
+	Data        []byte
+	URL         string
+	Seed        *int
+	ContentType string
+	Headers     map[string]string
 }
 
 func (c *Client) GenerateImage(ctx context.Context, req *ImageRequest) (*ImageResponse, error) {
 	if req == nil {
 		return nil, errors.New("image request is nil")
 	}
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	provider, err := c.newImageProvider(req)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := provider.Generate(ctx, req)
+	resp, err := provider.Generate(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	return &ImageResponse{Data: data}, nil
+	c.observeResponseBytes(req.Provider, len(resp.Data))
+	resp.Headers = captured.headers()
+	return resp, nil
+}
+
+// GenerateImageToFile generates an image and writes it to path. When path has
+// no extension, one matching the sniffed format is appended. It returns the
+// path actually written.
+func (c *Client) GenerateImageToFile(ctx context.Context, req *ImageRequest, path string) (string, error) {
+	if req != nil && req.ResponseFormat == ImageResponseURL {
+		return "", errors.New("image to file needs the image bytes, not ImageResponseURL")
+	}
+	resp, err := c.GenerateImage(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if filepath.Ext(path) == "" {
+		path += imageExtension(http.DetectContentType(resp.Data))
+	}
+	if err := os.WriteFile(path, resp.Data, 0o644); err != nil {
+		return "", fmt.Errorf("write image: %w", err)
+	}
+	return path, nil
+}
+
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+	"image/bmp":  ".bmp",
+}
+
+func imageExtension(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if ext, ok := imageExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// GenerateImageToolResult runs an image generation on behalf of a model's
+// tool call and wraps the image as a tool message, ready to be appended to
+// the conversation for the next turn.
+func (c *Client) GenerateImageToolResult(ctx context.Context, toolCallID string, req *ImageRequest) (Message, error) {
+	resp, err := c.GenerateImage(ctx, req)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var image ContentPart
+	if len(resp.Data) > 0 {
+		mediaType := http.DetectContentType(resp.Data)
+		if !strings.HasPrefix(mediaType, "image/") {
+			mediaType = "image/png"
+		}
+		image = NewImageBase64Part(mediaType, base64.StdEncoding.EncodeToString(resp.Data))
+	} else {
+		image = NewImageURLPart(resp.URL)
+	}
+	return NewToolMessageWithContentParts(toolCallID, []ContentPart{NewTextPart("Generated image: " + req.Prompt), image}), nil
+}
+
+// GenerateImageToolName is the function name of the built-in image tool.
+const GenerateImageToolName = "generate_image"
+
+// GenerateImageTool returns the OpenAI-style function definition of the
+// built-in image tool, to be listed in a chat request's tools. Calls the
+// model makes to it are answered by HandleGenerateImageToolCall.
+func GenerateImageTool() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        GenerateImageToolName,
+			"description": "Generate an image from a text description.",
+			"parameters": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"prompt": map[string]interface{}{"type": "string", "description": "What the image should show."},
+					"width":  map[string]interface{}{"type": "integer", "description": "Width in pixels."},
+					"height": map[string]interface{}{"type": "integer", "description": "Height in pixels."},
+				},
+				"required": []string{"prompt"},
+			},
+		},
+	}
+}
+
+// HandleGenerateImageToolCall answers a generate_image tool call: it decodes
+// the call's JSON arguments over base, which supplies the provider, model and
+// key, generates the image and returns it as the tool message for the call.
+func (c *Client) HandleGenerateImageToolCall(ctx context.Context, toolCallID, arguments string, base ImageRequest) (Message, error) {
+	var args struct {
+		Prompt string `json:"prompt"`
+		Width  *int   `json:"width"`
+		Height *int   `json:"height"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return Message{}, fmt.Errorf("decode %s arguments: %w", GenerateImageToolName, err)
+	}
+	if strings.TrimSpace(args.Prompt) == "" {
+		return Message{}, fmt.Errorf("%s: prompt is required", GenerateImageToolName)
+	}
+
+	req := base
+	req.Prompt = args.Prompt
+	if args.Width != nil {
+		req.Width = args.Width
+	}
+	if args.Height != nil {
+		req.Height = args.Height
+	}
+	return c.GenerateImageToolResult(ctx, toolCallID, &req)
 }
 
 func (c *Client) newImageProvider(req *ImageRequest) (imageProvider, error) {
@@ -48,20 +196,33 @@ func (c *Client) newImageProvider(req *ImageRequest) (imageProvider, error) {
 	switch name {
 	case "pollinations":
 		return &pollinationsImageProvider{client: c.httpClient}, nil
+	case "openai":
+		endpoint := req.Endpoint
+		if endpoint == "" {
+			endpoint = defaultOpenAIImagesURL
+		}
+		return &openAIImageProvider{endpoint: endpoint, client: c.httpClient}, nil
 	default:
-		return nil, fmt.Errorf("unknown image provider: %s", req.Provider)
+		endpoint := req.Endpoint
+		if isURL(name) {
+			endpoint = strings.TrimSpace(req.Provider)
+		}
+		if !isURL(endpoint) {
+			return nil, fmt.Errorf("unknown image provider: %s", req.Provider)
+		}
+		return &openAIImageProvider{endpoint: endpoint, client: c.httpClient}, nil
 	}
 }
 
 type imageProvider interface {
-	Generate(ctx context.Context, req *ImageRequest) ([]byte, error)
+	Generate(ctx context.Context, req *ImageRequest) (*ImageResponse, error)
 }
 
 type pollinationsImageProvider struct {
 	client *http.Client
 }
 
-func (p *pollinationsImageProvider) Generate(ctx context.Context, req *ImageRequest) ([]byte, error) {
+func (p *pollinationsImageProvider) Generate(ctx context.Context, req *ImageRequest) (*ImageResponse, error) {
 	encodedPrompt := url.PathEscape(req.Prompt)
 	endpoint := fmt.Sprintf("https://gen.pollinations.ai/image/%s", encodedPrompt)
 
@@ -78,11 +239,19 @@ func (p *pollinationsImageProvider) Generate(ctx context.Context, req *ImageRequ
 	if req.Seed != nil {
 		params.Set("seed", fmt.Sprintf("%d", *req.Seed))
 	}
+	if req.Quality != "" {
+		params.Set("quality", req.Quality)
+	}
 
 	if len(params) > 0 {
 		endpoint = endpoint + "?" + params.Encode()
 	}
 
+	// GET-провайдер: URL изображения совпадает с адресом запроса, скачивать не нужно.
+	if req.ResponseFormat == ImageResponseURL {
+		return &ImageResponse{URL: endpoint, Seed: req.Seed}, nil
+	}
+
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
@@ -98,14 +267,168 @@ func (p *pollinationsImageProvider) Generate(ctx context.Context, req *ImageRequ
 	}
 	defer resp.Body.Close()
 
-	data, err := io.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if req.Progress != nil && resp.StatusCode < 300 {
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, progress: req.Progress}
+	}
+
+	data, err := io.ReadAll(body)
 	if err != nil {
 		return nil, fmt.Errorf("read response: %w", err)
 	}
 
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(data))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(data), Provider: req.Provider}
+	}
+	if err := checkContentType(resp, data); err != nil {
+		return nil, err
+	}
+
+	seed := req.Seed
+	if seed == nil {
+		seed = responseImageSeed(resp)
+	}
+	return &ImageResponse{Data: data, URL: endpoint, Seed: seed, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+var imageSeedHeaders = []string{"X-Seed", "X-Image-Seed", "Seed"}
+
+// responseImageSeed recovers the seed Pollinations picked for a request
+// without one, from a response header or from the final URL after
+// redirects.
+func responseImageSeed(resp *http.Response) *int {
+	for _, name := range imageSeedHeaders {
+		if seed, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get(name))); err == nil {
+			return &seed
+		}
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		if seed, err := strconv.Atoi(resp.Request.URL.Query().Get("seed")); err == nil {
+			return &seed
+		}
+	}
+	return nil
+}
+
+// openAIImagePayload is the /v1/images/generations body. Seed is not part of
+// the OpenAI spec but is honoured by many compatible servers.
+type openAIImagePayload struct {
+	Model          string `json:"model,omitempty"`
+	Prompt         string `json:"prompt"`
+	N              int    `json:"n"`
+	Size           string `json:"size,omitempty"`
+	Quality        string `json:"quality,omitempty"`
+	Style          string `json:"style,omitempty"`
+	Seed           *int   `json:"seed,omitempty"`
+	ResponseFormat string `json:"response_format"`
+}
+
+func newOpenAIImagePayload(req *ImageRequest) *openAIImagePayload {
+	payload := &openAIImagePayload{
+		Model:          req.Model,
+		Prompt:         req.Prompt,
+		N:              1,
+		Quality:        req.Quality,
+		Style:          req.Style,
+		Seed:           req.Seed,
+		ResponseFormat: "b64_json",
+	}
+	if req.Width != nil && req.Height != nil {
+		payload.Size = fmt.Sprintf("%dx%d", *req.Width, *req.Height)
+	}
+	if req.ResponseFormat == ImageResponseURL {
+		payload.ResponseFormat = "url"
+	}
+	return payload
+}
+
+type openAIImageProvider struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (p *openAIImageProvider) Generate(ctx context.Context, req *ImageRequest) (*ImageResponse, error) {
+	respBody, err := postJSON(ctx, p.client, p.endpoint, newOpenAIImagePayload(req), req.APIKey, nil)
+	if err != nil {
+		return nil, withProvider(err, req.Provider)
+	}
+
+	var result struct {
+		Data []struct {
+			B64JSON string `json:"b64_json"`
+			URL     string `json:"url"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	if len(result.Data) == 0 {
+		return nil, errors.New("no image in response")
+	}
+	image := result.Data[0]
+
+	if req.ResponseFormat == ImageResponseURL || image.B64JSON == "" {
+		if image.URL == "" {
+			return nil, errors.New("no image in response")
+		}
+		if req.ResponseFormat == ImageResponseURL {
+			return &ImageResponse{URL: image.URL}, nil
+		}
+		return downloadImage(ctx, p.client, image.URL, req.Progress)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(image.B64JSON)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if req.Progress != nil {
+		req.Progress(int64(len(data)), int64(len(data)))
+	}
+	return &ImageResponse{Data: data, ContentType: http.DetectContentType(data)}, nil
+}
+
+func downloadImage(ctx context.Context, client *http.Client, imageURL string, progress func(downloaded, total int64)) (*ImageResponse, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body io.Reader = resp.Body
+	if progress != nil && resp.StatusCode < 300 {
+		body = &progressReader{r: resp.Body, total: resp.ContentLength, progress: progress}
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(data)}
 	}
 
-	return data, nil
+	return &ImageResponse{Data: data, URL: imageURL, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+// progressReader reports bytes read so far; total is -1 when the server
+// did not send Content-Length.
+type progressReader struct {
+	r          io.Reader
+	downloaded int64
+	total      int64
+	progress   func(downloaded, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.downloaded += int64(n)
+		p.progress(p.downloaded, p.total)
+	}
+	return n, err
 }
@@ -0,0 +1,72 @@
+package llmclient
+
+import "strings"
+
+// imagePart is the provider-neutral form of an image attached to a message,
+// built from Request.Images entries and image_url content parts. Each
+// provider serializes it in its own convention. There is no Gemini shape
+// (inline_data/file_data): no chat provider here speaks the native Gemini
+// API, and its OpenAI-compatible endpoint takes the openAI form.
+type imagePart struct {
+	url      string
+	detail   string
+	mimeType string
+}
+
+func newImagePart(img *ImageURL) imagePart {
+	return imagePart{url: img.URL, detail: img.Detail, mimeType: img.MimeType}
+}
+
+// dataURL returns URLs and data URLs unchanged and wraps raw base64 in a
+// data URL, using mimeType or else the type detected from the bytes.
+func (p imagePart) dataURL() string {
+	if isURL(p.url) || strings.HasPrefix(p.url, "data:") {
+		return p.url
+	}
+	mediaType := p.mimeType
+	if mediaType == "" {
+		mediaType = guessBase64ImageType(p.url)
+	}
+	return "data:" + mediaType + ";base64," + p.url
+}
+
+// inline splits an embedded image into media type and base64 payload; ok is
+// false for remote URLs.
+func (p imagePart) inline() (mediaType, data string, ok bool) {
+	if isURL(p.url) {
+		return "", "", false
+	}
+	header, data, _ := strings.Cut(strings.TrimPrefix(p.dataURL(), "data:"), ",")
+	return strings.TrimSuffix(header, ";base64"), data, true
+}
+
+// openAI: {"type":"image_url","image_url":{"url","detail"}}.
+func (p imagePart) openAI() map[string]interface{} {
+	imageURL := map[string]interface{}{"url": p.dataURL()}
+	if p.detail != "" {
+		imageURL["detail"] = p.detail
+	}
+	return map[string]interface{}{"type": "image_url", "image_url": imageURL}
+}
+
+// anthropic: {"type":"image","source":{...}} with a base64 or url source.
+func (p imagePart) anthropic() map[string]interface{} {
+	if mediaType, data, ok := p.inline(); ok {
+		return map[string]interface{}{
+			"type":   "image",
+			"source": map[string]interface{}{"type": "base64", "media_type": mediaType, "data": data},
+		}
+	}
+	return map[string]interface{}{
+		"type":   "image",
+		"source": map[string]interface{}{"type": "url", "url": p.url},
+	}
+}
+
+// ollama: the native API takes bare base64 in the message's images list.
+func (p imagePart) ollama() string {
+	if _, data, ok := p.inline(); ok {
+		return data
+	}
+	return p.url
+}
@@ -0,0 +1,158 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImagePartNormalisation(t *testing.T) {
+	png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	jpeg := base64.StdEncoding.EncodeToString([]byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"))
+	tests := []struct {
+		name          string
+		image         ImageURL
+		wantURL       string
+		wantMediaType string
+		wantData      string
+		wantInline    bool
+		wantOllama    string
+	}{
+		{"raw base64 png", ImageURL{URL: png},
+			"data:image/png;base64," + png, "image/png", png, true, png},
+		{"raw base64 jpeg", ImageURL{URL: jpeg},
+			"data:image/jpeg;base64," + jpeg, "image/jpeg", jpeg, true, jpeg},
+		{"raw base64 with mime type", ImageURL{URL: png, MimeType: "image/webp"},
+			"data:image/webp;base64," + png, "image/webp", png, true, png},
+		{"data url", ImageURL{URL: "data:image/gif;base64,R0lGODlh"},
+			"data:image/gif;base64,R0lGODlh", "image/gif", "R0lGODlh", true, "R0lGODlh"},
+		{"http url", ImageURL{URL: "https://example.com/cat.png"},
+			"https://example.com/cat.png", "", "", false, "https://example.com/cat.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newImagePart(&tt.image)
+			if got := p.dataURL(); got != tt.wantURL {
+				t.Errorf("dataURL() = %q, want %q", got, tt.wantURL)
+			}
+			mediaType, data, ok := p.inline()
+			if mediaType != tt.wantMediaType || data != tt.wantData || ok != tt.wantInline {
+				t.Errorf("inline() = %q, %q, %v, want %q, %q, %v", mediaType, data, ok, tt.wantMediaType, tt.wantData, tt.wantInline)
+			}
+			if got := p.ollama(); got != tt.wantOllama {
+				t.Errorf("ollama() = %q, want %q", got, tt.wantOllama)
+			}
+			want := map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": tt.wantURL}}
+			if got := p.openAI(); !reflect.DeepEqual(got, want) {
+				t.Errorf("openAI() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestImagePartFromFile(t *testing.T) {
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+	pngPath := filepath.Join(dir, "chart.png")
+	textPath := filepath.Join(dir, "notes.png")
+	if err := os.WriteFile(pngPath, png, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(textPath, []byte("not an image"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	part, err := NewImagePartFromFile(pngPath)
+	if err != nil {
+		t.Fatalf("NewImagePartFromFile: %v", err)
+	}
+	if part.Type != "image_url" || part.ImageURL == nil || part.ImageURL.URL != want {
+		t.Errorf("part = %+v, want url %q", part, want)
+	}
+	images, err := ImagesFromFiles(pngPath, pngPath)
+	if err != nil {
+		t.Fatalf("ImagesFromFiles: %v", err)
+	}
+	if !reflect.DeepEqual(images, []string{want, want}) {
+		t.Errorf("images = %q", images)
+	}
+
+	// The type comes from the bytes, not the extension.
+	for _, path := range []string{textPath, filepath.Join(dir, "missing.png")} {
+		if _, err := NewImagePartFromFile(path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+		if _, err := ImagesFromFiles(pngPath, path); err == nil {
+			t.Errorf("%s: ImagesFromFiles expected an error", path)
+		}
+	}
+}
+
+func TestImagePartShapesInPayload(t *testing.T) {
+	var payload map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payload = decodeBody(t, body)
+		switch r.URL.Path {
+		case "/v1/messages":
+			io.WriteString(w, `{"content":[{"type":"text","text":"ok"}]}`)
+		case "/api/chat":
+			io.WriteString(w, `{"message":{"role":"assistant","content":"ok"},"done":true}`+"\n")
+		default:
+			io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+		}
+	}))
+	defer srv.Close()
+
+	raw := "iVBORw0KGgoAAAANSUhEUg=="
+	remote := "https://example.com/cat.jpg"
+	parts := []ContentPart{
+		NewTextPart("Compare"),
+		NewImageBase64PartWithDetail("image/webp", raw, "low"),
+		NewImageURLPartWithDetail(remote, "high"),
+	}
+	tests := []struct {
+		name     string
+		provider string
+		endpoint string
+		want     interface{}
+	}{
+		{"openai", "openai", srv.URL + "/v1/chat/completions", []interface{}{
+			map[string]interface{}{"type": "text", "text": "Compare"},
+			map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/webp;base64," + raw, "detail": "low"}},
+			map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": remote, "detail": "high"}},
+		}},
+		{"anthropic", "anthropic", srv.URL + "/v1/messages", []interface{}{
+			map[string]interface{}{"type": "text", "text": "Compare"},
+			map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/webp", "data": raw}},
+			map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "url", "url": remote}},
+		}},
+		{"ollama native", "ollama", srv.URL + "/api/chat", []interface{}{raw, remote}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Provider: tt.provider, Endpoint: tt.endpoint, Model: "m", Messages: []Message{NewUserMessageWithContentParts(parts)}}
+			if _, err := NewClient().Send(context.Background(), req); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			msg := sentMessages(payload)[0]
+			got := msg["content"]
+			if tt.provider == "ollama" {
+				got = msg["images"]
+				if msg["content"] != "Compare" {
+					t.Errorf("content = %v", msg["content"])
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("image parts = %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,454 @@
+package llmclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateImageTool(t *testing.T) {
+	raw, err := json.Marshal(GenerateImageTool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tool struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name       string `json:"name"`
+			Parameters struct {
+				Properties map[string]interface{} `json:"properties"`
+				Required   []string               `json:"required"`
+			} `json:"parameters"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &tool); err != nil {
+		t.Fatal(err)
+	}
+	if tool.Type != "function" || tool.Function.Name != GenerateImageToolName {
+		t.Errorf("tool = %s", raw)
+	}
+	if _, ok := tool.Function.Parameters.Properties["prompt"]; !ok {
+		t.Errorf("no prompt parameter in %s", raw)
+	}
+	if len(tool.Function.Parameters.Required) != 1 || tool.Function.Parameters.Required[0] != "prompt" {
+		t.Errorf("required = %v", tool.Function.Parameters.Required)
+	}
+}
+
+func TestHandleGenerateImageToolCall(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n fake image data")
+	var gotPath, gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithHTTPClient(rewriteClient(srv)))
+	base := ImageRequest{Provider: "pollinations", Model: "flux"}
+	msg, err := c.HandleGenerateImageToolCall(context.Background(), "call_1",
+		`{"prompt":"a red fox","width":512,"height":256}`, base)
+	if err != nil {
+		t.Fatalf("HandleGenerateImageToolCall: %v", err)
+	}
+
+	if gotPath != "/image/a red fox" {
+		t.Errorf("path = %q", gotPath)
+	}
+	for _, want := range []string{"model=flux", "width=512", "height=256"} {
+		if !strings.Contains(gotQuery, want) {
+			t.Errorf("query %q lacks %s", gotQuery, want)
+		}
+	}
+	if msg.Role != "tool" || msg.ToolCallID != "call_1" {
+		t.Errorf("role = %q, tool_call_id = %q", msg.Role, msg.ToolCallID)
+	}
+
+	var image *ImageURL
+	for _, part := range msg.ContentParts {
+		if part.ImageURL != nil {
+			image = part.ImageURL
+		}
+	}
+	if image == nil {
+		t.Fatalf("no image part in %+v", msg.ContentParts)
+	}
+	prefix := "data:image/png;base64,"
+	if !strings.HasPrefix(image.URL, prefix) {
+		t.Fatalf("image url = %q", image.URL)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(image.URL, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(png) {
+		t.Errorf("image bytes = %q, want %q", data, png)
+	}
+}
+
+func TestHandleGenerateImageToolCallBadArguments(t *testing.T) {
+	c := NewClient()
+	base := ImageRequest{Provider: "pollinations"}
+	for _, args := range []string{`not json`, `{"width":512}`} {
+		if _, err := c.HandleGenerateImageToolCall(context.Background(), "call_1", args, base); err == nil {
+			t.Errorf("arguments %s: expected an error", args)
+		}
+	}
+}
+
+func TestImageProgress(t *testing.T) {
+	image := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0xAB}, 100<<10)...)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+		// Written in pieces so the client sees several reads.
+		for i := 0; i < len(image); i += 16 << 10 {
+			end := i + 16<<10
+			if end > len(image) {
+				end = len(image)
+			}
+			w.Write(image[i:end])
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer srv.Close()
+
+	type call struct{ downloaded, total int64 }
+	var calls []call
+	c := NewClient(WithHTTPClient(rewriteClient(srv)))
+	resp, err := c.GenerateImage(context.Background(), &ImageRequest{
+		Provider: "pollinations",
+		Prompt:   "a cat",
+		Progress: func(downloaded, total int64) { calls = append(calls, call{downloaded, total}) },
+	})
+	if err != nil {
+		t.Fatalf("GenerateImage: %v", err)
+	}
+	if len(resp.Data) != len(image) {
+		t.Fatalf("got %d bytes, want %d", len(resp.Data), len(image))
+	}
+	if len(calls) < 2 {
+		t.Fatalf("progress called %d times, want several", len(calls))
+	}
+	for i, c := range calls {
+		if c.total != int64(len(image)) {
+			t.Errorf("call %d total = %d, want %d", i, c.total, len(image))
+		}
+		if i > 0 && c.downloaded <= calls[i-1].downloaded {
+			t.Errorf("call %d downloaded = %d, not above %d", i, c.downloaded, calls[i-1].downloaded)
+		}
+	}
+	if last := calls[len(calls)-1]; last.downloaded != int64(len(image)) {
+		t.Errorf("last call downloaded = %d, want %d", last.downloaded, len(image))
+	}
+}
+
+func TestImagePOSTPayload(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n fake image data")
+	var (
+		method, auth string
+		payload      map[string]interface{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, auth = r.Method, r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		payload = decodeBody(t, body)
+		if payload["response_format"] == "url" {
+			io.WriteString(w, `{"data":[{"url":"https://example.com/a.png"}]}`)
+			return
+		}
+		io.WriteString(w, `{"data":[{"b64_json":"`+base64.StdEncoding.EncodeToString(png)+`"}]}`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		opts []ImageOption
+		want map[string]interface{}
+	}{
+		{"all params", []ImageOption{WithImageWidth(1024), WithImageHeight(768), WithImageSeed(42), WithImageQuality("hd"), WithImageStyle("vivid")},
+			map[string]interface{}{"model": "dall-e-3", "prompt": "a lighthouse", "n": 1.0, "size": "1024x768",
+				"seed": 42.0, "quality": "hd", "style": "vivid", "response_format": "b64_json"}},
+		{"seed zero is sent", []ImageOption{WithImageSeed(0)},
+			map[string]interface{}{"model": "dall-e-3", "prompt": "a lighthouse", "n": 1.0, "seed": 0.0, "response_format": "b64_json"}},
+		{"width without height has no size", []ImageOption{WithImageWidth(512)},
+			map[string]interface{}{"model": "dall-e-3", "prompt": "a lighthouse", "n": 1.0, "response_format": "b64_json"}},
+		{"url response format", []ImageOption{WithImageResponseFormat(ImageResponseURL)},
+			map[string]interface{}{"model": "dall-e-3", "prompt": "a lighthouse", "n": 1.0, "response_format": "url"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &ImageRequest{Provider: srv.URL + "/v1/images/generations", Model: "dall-e-3", APIKey: "sk-test", Prompt: "a lighthouse"}
+			for _, opt := range tt.opts {
+				opt(req)
+			}
+			resp, err := NewClient().GenerateImage(context.Background(), req)
+			if err != nil {
+				t.Fatalf("GenerateImage: %v", err)
+			}
+			if req.ResponseFormat == ImageResponseURL {
+				if resp.URL != "https://example.com/a.png" {
+					t.Errorf("url = %q", resp.URL)
+				}
+			} else if !bytes.Equal(resp.Data, png) {
+				t.Errorf("data = %q", resp.Data)
+			}
+			if method != http.MethodPost || auth != "Bearer sk-test" {
+				t.Errorf("method = %s, Authorization = %q", method, auth)
+			}
+			if !reflect.DeepEqual(payload, tt.want) {
+				t.Errorf("payload = %v\nwant %v", payload, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateImageToolRoundTrip(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n fake image data")
+	var chats []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/image/") {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		chats = append(chats, decodeBody(t, body))
+		if len(chats) == 1 {
+			io.WriteString(w, `{"choices":[{"finish_reason":"tool_calls","message":{"role":"assistant","content":null,"tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"generate_image","arguments":"{\"prompt\":\"a red fox\"}"}}
+			]}}]}`)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"finish_reason":"stop","message":{"content":"Here is your fox."}}]}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithHTTPClient(rewriteClient(srv)))
+	messages := []Message{NewUserMessage("Draw a fox")}
+	req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Messages: messages}
+	WithTools(GenerateImageTool())(req)
+	resp, err := c.Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("first Send: %v", err)
+	}
+	if resp.FinishReason != "tool_calls" || len(resp.ToolCalls) != 1 {
+		t.Fatalf("finish reason = %q, tool calls = %+v", resp.FinishReason, resp.ToolCalls)
+	}
+	call := resp.ToolCalls[0]
+	if call.ID != "call_1" || call.Type != "function" || call.Function.Name != GenerateImageToolName {
+		t.Fatalf("tool call = %+v", call)
+	}
+	tools, _ := chats[0]["tools"].([]interface{})
+	if len(tools) != 1 {
+		t.Fatalf("tools sent = %v", chats[0]["tools"])
+	}
+	if name := tools[0].(map[string]interface{})["function"].(map[string]interface{})["name"]; name != GenerateImageToolName {
+		t.Errorf("tool name sent = %v", name)
+	}
+
+	result, err := c.HandleGenerateImageToolCall(context.Background(), call.ID, call.Function.Arguments, ImageRequest{Provider: "pollinations"})
+	if err != nil {
+		t.Fatalf("HandleGenerateImageToolCall: %v", err)
+	}
+	req.Messages = append(messages, NewAssistantToolCallsMessage("", resp.ToolCalls), result)
+	resp, err = c.Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("second Send: %v", err)
+	}
+	if resp.Content != "Here is your fox." {
+		t.Errorf("content = %q", resp.Content)
+	}
+
+	sent := sentMessages(chats[1])
+	if len(sent) != 3 {
+		t.Fatalf("messages = %v", sent)
+	}
+	assistant := sent[1]
+	wantCalls := []interface{}{map[string]interface{}{
+		"id": "call_1", "type": "function",
+		"function": map[string]interface{}{"name": "generate_image", "arguments": `{"prompt":"a red fox"}`},
+	}}
+	if assistant["role"] != "assistant" || assistant["content"] != nil || !reflect.DeepEqual(assistant["tool_calls"], wantCalls) {
+		t.Errorf("assistant message = %v", assistant)
+	}
+	tool := sent[2]
+	if tool["role"] != "tool" || tool["tool_call_id"] != "call_1" {
+		t.Errorf("tool message = %v", tool)
+	}
+	parts, _ := tool["content"].([]interface{})
+	wantURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	var gotURL interface{}
+	for _, p := range parts {
+		if image, ok := p.(map[string]interface{})["image_url"].(map[string]interface{}); ok {
+			gotURL = image["url"]
+		}
+	}
+	if gotURL != wantURL {
+		t.Errorf("tool image url = %v, want %q", gotURL, wantURL)
+	}
+}
+
+func TestImageSeedFromResponse(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\nfake"
+	seed := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		seed    *int
+		header  string
+		headerV string
+		redir   bool
+		want    *int
+	}{
+		{"request seed wins", seed(7), "X-Seed", "99", false, seed(7)},
+		{"X-Seed header", nil, "X-Seed", " 42 ", false, seed(42)},
+		{"Seed header", nil, "Seed", "43", false, seed(43)},
+		{"redirect to a seeded URL", nil, "", "", true, seed(44)},
+		{"not reported", nil, "X-Seed", "random", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.redir && r.URL.Query().Get("seed") == "" {
+					http.Redirect(w, r, r.URL.Path+"?seed=44", http.StatusFound)
+					return
+				}
+				query = r.URL.RawQuery
+				if tt.header != "" {
+					w.Header().Set(tt.header, tt.headerV)
+				}
+				w.Header().Set("Content-Type", "image/png")
+				io.WriteString(w, png)
+			}))
+			defer srv.Close()
+
+			resp, err := NewClient(WithHTTPClient(rewriteClient(srv))).GenerateImage(context.Background(), &ImageRequest{Provider: "pollinations", Prompt: "a cat", Seed: tt.seed})
+			if err != nil {
+				t.Fatalf("GenerateImage: %v", err)
+			}
+			if !reflect.DeepEqual(resp.Seed, tt.want) {
+				t.Errorf("seed = %v, want %v", resp.Seed, tt.want)
+			}
+			if tt.seed != nil && query != "seed=7" {
+				t.Errorf("query = %q, want the request seed", query)
+			}
+		})
+	}
+}
+
+func TestGenerateImageToFile(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), bytes.Repeat([]byte{0}, 32)...)
+	tests := []struct {
+		name        string
+		contentType string
+		path        string
+		wantPath    string
+	}{
+		{"extension added", "image/png", "cat", "cat.png"},
+		{"sniffed, not taken from the header", "application/octet-stream", "cat", "cat.png"},
+		{"extension kept", "image/png", "cat.img", "cat.img"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write(png)
+			}))
+			defer srv.Close()
+			c := NewClient(WithHTTPClient(rewriteClient(srv)))
+
+			resp, err := c.GenerateImage(context.Background(), &ImageRequest{Provider: "pollinations", Prompt: "a cat"})
+			if err != nil {
+				t.Fatalf("GenerateImage: %v", err)
+			}
+			if resp.ContentType != tt.contentType {
+				t.Errorf("ContentType = %q, want the response header %q", resp.ContentType, tt.contentType)
+			}
+
+			dir := t.TempDir()
+			path, err := c.GenerateImageToFile(context.Background(), &ImageRequest{Provider: "pollinations", Prompt: "a cat"}, filepath.Join(dir, tt.path))
+			if err != nil {
+				t.Fatalf("GenerateImageToFile: %v", err)
+			}
+			if want := filepath.Join(dir, tt.wantPath); path != want {
+				t.Errorf("path = %q, want %q", path, want)
+			}
+			if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, png) {
+				t.Errorf("file holds %d bytes (%v), want the PNG body", len(data), err)
+			}
+		})
+	}
+
+	_, err := NewClient().GenerateImageToFile(context.Background(), &ImageRequest{Provider: "pollinations", Prompt: "a cat", ResponseFormat: ImageResponseURL}, "cat")
+	if err == nil {
+		t.Error("ImageResponseURL: want an error, the file needs the image bytes")
+	}
+}
+
+func TestGenericImageEndpoint(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nfake sd output")
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/sdapi/v1/images/generations":
+			io.WriteString(w, `{"created":1,"data":[{"b64_json":"`+base64.StdEncoding.EncodeToString(png)+`"}]}`)
+		case "/url/v1/images/generations":
+			io.WriteString(w, `{"data":[{"url":"http://`+r.Host+`/outputs/1.png"}]}`)
+		case "/outputs/1.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `{"error":"CUDA out of memory"}`)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		provider  string
+		opts      []ImageOption
+		wantPaths []string
+		wantErr   string
+	}{
+		{"URL as provider", srv.URL + "/sdapi/v1/images/generations", nil, []string{"/sdapi/v1/images/generations"}, ""},
+		{"WithImageEndpoint", "local-sd", []ImageOption{WithImageEndpoint(srv.URL + "/sdapi/v1/images/generations")}, []string{"/sdapi/v1/images/generations"}, ""},
+		{"image URL downloaded", srv.URL + "/url/v1/images/generations", nil, []string{"/url/v1/images/generations", "/outputs/1.png"}, ""},
+		{"server error", srv.URL + "/broken/v1/images/generations", nil, []string{"/broken/v1/images/generations"}, "CUDA out of memory"},
+		{"unknown provider", "local-sd", nil, nil, "unknown image provider: local-sd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths = nil
+			data, err := GenerateImageWithContext(context.Background(), tt.provider, "sdxl", "", "a lighthouse", tt.opts...)
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("requests = %q, want %q", paths, tt.wantPaths)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateImage: %v", err)
+			}
+			if !bytes.Equal(data, png) {
+				t.Errorf("data = %q", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package llmclient
+
+import "strings"
+
+// InjectionSlot names one of the message groups assembled for a chat or
+// stream request; WithContextInjectionOrder arranges them.
+type InjectionSlot string
+
+const (
+	InjectSystem  InjectionSlot = "system"
+	InjectFewShot InjectionSlot = "fewshot"
+	InjectContext InjectionSlot = "context"
+	InjectHistory InjectionSlot = "history"
+)
+
+var defaultInjectionOrder = []InjectionSlot{InjectSystem, InjectFewShot, InjectContext, InjectHistory}
+
+// WithContextInjectionOrder sets where the system prompt, few-shot examples,
+// retrieved context and conversation history go. Slots left out keep their
+// default relative order after the listed ones. The system prompt and the
+// context are system text: placed ahead of every message they form the
+// system prompt, placed after the few-shot examples or the history they are
+// prepended to the first user turn of the history.
+func WithContextInjectionOrder(order ...InjectionSlot) ClientOption {
+	return func(c *Client) {
+		seen := make(map[InjectionSlot]bool, len(defaultInjectionOrder))
+		c.injectionOrder = nil
+		for _, slot := range append(order, defaultInjectionOrder...) {
+			if !seen[slot] && isInjectionSlot(slot) {
+				seen[slot] = true
+				c.injectionOrder = append(c.injectionOrder, slot)
+			}
+		}
+	}
+}
+
+func isInjectionSlot(slot InjectionSlot) bool {
+	for _, s := range defaultInjectionOrder {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+// contextText joins retrieved documents into one block of system text.
+func contextText(docs []string) string {
+	if len(docs) == 0 {
+		return ""
+	}
+	return "Context:\n\n" + strings.Join(docs, "\n\n")
+}
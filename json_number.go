@@ -0,0 +1,141 @@
+package llmclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// jsonInt decodes token counts that providers send as integers, floats
+// ("12.0") or strings ("12"). null and "" decode to zero.
+type jsonInt int64
+
+func (n *jsonInt) UnmarshalJSON(data []byte) error {
+	f, err := parseJSONNumber(data)
+	if err != nil {
+		return err
+	}
+	*n = jsonInt(math.Round(f))
+	return nil
+}
+
+// jsonFloat is jsonInt for costs.
+type jsonFloat float64
+
+func (n *jsonFloat) UnmarshalJSON(data []byte) error {
+	f, err := parseJSONNumber(data)
+	if err != nil {
+		return err
+	}
+	*n = jsonFloat(f)
+	return nil
+}
+
+func parseJSONNumber(data []byte) (float64, error) {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return 0, nil
+	}
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return 0, err
+		}
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return 0, nil
+		}
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return float64(i), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", data)
+	}
+	return f, nil
+}
+
+func (u *TokenUsage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		PromptTokens     jsonInt `json:"prompt_tokens"`
+		CompletionTokens jsonInt `json:"completion_tokens"`
+		TotalTokens      jsonInt `json:"total_tokens"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*u = TokenUsage{
+		PromptTokens:     int64(raw.PromptTokens),
+		CompletionTokens: int64(raw.CompletionTokens),
+		TotalTokens:      int64(raw.TotalTokens),
+	}
+	return nil
+}
+
+func (u *ProfileUsage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		TotalTokens      jsonInt   `json:"total_tokens"`
+		PromptTokens     jsonInt   `json:"prompt_tokens"`
+		CompletionTokens jsonInt   `json:"completion_tokens"`
+		TotalRequests    jsonInt   `json:"total_requests"`
+		TotalCost        jsonFloat `json:"total_cost"`
+		PeriodStart      string    `json:"period_start"`
+		PeriodEnd        string    `json:"period_end"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*u = ProfileUsage{
+		TotalTokens:      int64(raw.TotalTokens),
+		PromptTokens:     int64(raw.PromptTokens),
+		CompletionTokens: int64(raw.CompletionTokens),
+		TotalRequests:    int64(raw.TotalRequests),
+		TotalCost:        float64(raw.TotalCost),
+		PeriodStart:      raw.PeriodStart,
+		PeriodEnd:        raw.PeriodEnd,
+	}
+	return nil
+}
+
+func (t *UsageTotals) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		TotalRequests    jsonInt   `json:"total_requests"`
+		PromptTokens     jsonInt   `json:"prompt_tokens"`
+		CompletionTokens jsonInt   `json:"completion_tokens"`
+		TotalTokens      jsonInt   `json:"total_tokens"`
+		TotalCost        jsonFloat `json:"total_cost"`
+		Currency         string    `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*t = UsageTotals{
+		TotalRequests:    int64(raw.TotalRequests),
+		PromptTokens:     int64(raw.PromptTokens),
+		CompletionTokens: int64(raw.CompletionTokens),
+		TotalTokens:      int64(raw.TotalTokens),
+		TotalCost:        float64(raw.TotalCost),
+		Currency:         raw.Currency,
+	}
+	return nil
+}
+
+func (r *UsageRecord) UnmarshalJSON(data []byte) error {
+	type plain UsageRecord
+	var raw struct {
+		*plain
+		Tokens jsonInt   `json:"tokens"`
+		Cost   jsonFloat `json:"cost"`
+	}
+	raw.plain = (*plain)(r)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	r.Tokens = int64(raw.Tokens)
+	r.Cost = float64(raw.Cost)
+	return nil
+}
@@ -0,0 +1,82 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONInt(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    jsonInt
+		wantErr bool
+	}{
+		{`12`, 12, false},
+		{`"12"`, 12, false},
+		{`" 12 "`, 12, false},
+		{`12.0`, 12, false},
+		{`"12.6"`, 13, false},
+		{`null`, 0, false},
+		{`""`, 0, false},
+		{`"twelve"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		var n jsonInt
+		err := json.Unmarshal([]byte(tt.in), &n)
+		if (err != nil) != tt.wantErr || n != tt.want {
+			t.Errorf("%s: got %d, err %v; want %d, error %v", tt.in, n, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTokenCountsAsStrings(t *testing.T) {
+	var usage TokenUsage
+	if err := json.Unmarshal([]byte(`{"prompt_tokens":"9","completion_tokens":"2.0","total_tokens":"11"}`), &usage); err != nil {
+		t.Fatalf("TokenUsage: %v", err)
+	}
+	if want := (TokenUsage{PromptTokens: 9, CompletionTokens: 2, TotalTokens: 11}); usage != want {
+		t.Errorf("TokenUsage = %+v, want %+v", usage, want)
+	}
+
+	var record UsageRecord
+	if err := json.Unmarshal([]byte(`{"model":"openai","tokens":"120","cost":"0.0042"}`), &record); err != nil {
+		t.Fatalf("UsageRecord: %v", err)
+	}
+	if record.Model != "openai" || record.Tokens != 120 || record.Cost != 0.0042 {
+		t.Errorf("UsageRecord = %+v", record)
+	}
+
+	var profile ProfileUsage
+	if err := json.Unmarshal([]byte(`{"total_tokens":"1500","total_requests":"3","total_cost":"0.5"}`), &profile); err != nil {
+		t.Fatalf("ProfileUsage: %v", err)
+	}
+	if profile.TotalTokens != 1500 || profile.TotalRequests != 3 || profile.TotalCost != 0.5 {
+		t.Errorf("ProfileUsage = %+v", profile)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}],"usage":{"prompt_tokens":"5","completion_tokens":"1","total_tokens":"6"}}`)
+	}))
+	defer srv.Close()
+	resp, err := NewClient().Send(context.Background(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 6 || resp.Usage.PromptTokens != 5 {
+		t.Errorf("Response.Usage = %+v", resp.Usage)
+	}
+
+	streamSrv := sseServer(t, deltaEvent("ok"), `{"choices":[],"usage":{"prompt_tokens":"5","completion_tokens":"1","total_tokens":"6"}}`)
+	_, stream, err := collectStream(t, NewClient(), &Request{Provider: streamSrv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("SendStream: %v", err)
+	}
+	if stream.Usage == nil || stream.Usage.TotalTokens != 6 {
+		t.Errorf("StreamResponse.Usage = %+v", stream.Usage)
+	}
+}
@@ -0,0 +1,103 @@
+package llmclient
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LogEvent describes one HTTP exchange with a provider. Retries are logged
+// as separate events. For streams the event fires when the body is closed,
+// so ResponseBody holds the whole stream and Duration covers it.
+type LogEvent struct {
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	RequestBody    []byte
+	StatusCode     int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	Duration       time.Duration
+	Err            error
+}
+
+// redactedHeaders carry credentials and are masked in log events unless
+// WithLogRedaction(false) is set.
+var redactedHeaders = []string{"Authorization", "X-Api-Key", "Api-Key"}
+
+// WithLogger calls fn for every request the client sends. Without a logger
+// no wrapping transport is installed.
+func WithLogger(fn func(event LogEvent)) ClientOption {
+	return func(c *Client) { c.logger = fn }
+}
+
+func WithLogRedaction(enabled bool) ClientOption {
+	return func(c *Client) { c.noLogRedaction = !enabled }
+}
+
+type loggingTransport struct {
+	base   http.RoundTripper
+	log    func(LogEvent)
+	redact bool
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	event := LogEvent{Method: req.Method, URL: req.URL.String(), RequestHeader: req.Header.Clone()}
+	if t.redact {
+		for _, name := range redactedHeaders {
+			if event.RequestHeader.Get(name) != "" {
+				event.RequestHeader.Set(name, "REDACTED")
+			}
+		}
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			event.RequestBody, _ = io.ReadAll(body)
+			body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		event.Duration = time.Since(start)
+		event.Err = err
+		t.log(event)
+		return resp, err
+	}
+	event.StatusCode = resp.StatusCode
+	event.ResponseHeader = resp.Header.Clone()
+	resp.Body = &loggedBody{ReadCloser: resp.Body, done: func(body []byte, readErr error) {
+		event.ResponseBody = body
+		event.Duration = time.Since(start)
+		event.Err = readErr
+		t.log(event)
+	}}
+	return resp, nil
+}
+
+// loggedBody copies what the caller reads and reports it once on Close.
+type loggedBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	err  error
+	once sync.Once
+	done func(body []byte, err error)
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *loggedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.buf.Bytes(), b.err) })
+	return err
+}
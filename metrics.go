@@ -0,0 +1,83 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// MetricsRecorder receives the size in bytes of every response body the
+// client reads; for streams that is the raw event stream, not the decoded
+// text.
+type MetricsRecorder interface {
+	ObserveResponseBytes(provider string, n int)
+}
+
+func WithMetricsRecorder(m MetricsRecorder) ClientOption {
+	return func(c *Client) { c.metrics = m }
+}
+
+func (c *Client) observeResponseBytes(provider string, n int) {
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.ObserveResponseBytes(provider, n)
+}
+
+type byteCounterKey struct{}
+
+// byteCounter sums the response body bytes read during one streaming call,
+// which the parsers consume without ever holding the whole body.
+type byteCounter struct {
+	n atomic.Int64
+}
+
+func (c *Client) startByteCount(ctx context.Context) (context.Context, *byteCounter) {
+	if c.metrics == nil {
+		return ctx, nil
+	}
+	counter := &byteCounter{}
+	return context.WithValue(ctx, byteCounterKey{}, counter), counter
+}
+
+// reset drops the bytes of a failed attempt, so only the stream that was
+// delivered is reported.
+func (b *byteCounter) reset() {
+	if b != nil {
+		b.n.Store(0)
+	}
+}
+
+func (b *byteCounter) count() int {
+	if b == nil {
+		return 0
+	}
+	return int(b.n.Load())
+}
+
+type byteCountTransport struct {
+	base http.RoundTripper
+}
+
+func (t *byteCountTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if counter, ok := req.Context().Value(byteCounterKey{}).(*byteCounter); ok {
+		resp.Body = &countingBody{ReadCloser: resp.Body, counter: counter}
+	}
+	return resp, nil
+}
+
+type countingBody struct {
+	io.ReadCloser
+	counter *byteCounter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.counter.n.Add(int64(n))
+	return n, err
+}
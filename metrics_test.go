@@ -0,0 +1,76 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type recordedBytes struct {
+	mu  sync.Mutex
+	obs []int
+}
+
+func (r *recordedBytes) ObserveResponseBytes(provider string, n int) {
+	r.mu.Lock()
+	r.obs = append(r.obs, n)
+	r.mu.Unlock()
+}
+
+func TestObserveResponseBytes(t *testing.T) {
+	bodies := map[string]string{
+		"/v1/chat/completions":     `{"choices":[{"message":{"content":"hello"}}]}`,
+		"/stream":                  "data: " + deltaEvent("hel") + "\n\ndata: " + deltaEvent("lo") + "\n\ndata: [DONE]\n\n",
+		"/v1/audio/transcriptions": "data: {\"type\":\"transcript.text.delta\",\"delta\":\"hi\"}\n\ndata: {\"type\":\"transcript.text.done\",\"text\":\"hi\"}\n\n",
+		"/account/balance":         `{"balance":12.5,"currency":"USD"}`,
+		"/text/models":             `[{"name":"openai","aliases":["gpt"]}]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stream" || r.URL.Path == "/v1/audio/transcriptions" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		io.WriteString(w, bodies[r.URL.Path])
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		path string
+		call func(c *Client) error
+	}{
+		{"send", "/v1/chat/completions", func(c *Client) error {
+			_, err := c.Send(context.Background(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+			return err
+		}},
+		{"stream counts raw SSE bytes", "/stream", func(c *Client) error {
+			_, err := c.SendStream(context.Background(), &Request{Provider: "openai", Endpoint: srv.URL + "/stream", Model: "m", Prompt: "hi"}, func(StreamChunk) error { return nil })
+			return err
+		}},
+		{"transcription stream", "/v1/audio/transcriptions", func(c *Client) error {
+			return c.TranscribeAudioStream(context.Background(), &TranscriptionRequest{Provider: "pollinations", FileName: "a.wav", FileData: []byte("RIFF")}, func(TranscriptionChunk) error { return nil })
+		}},
+		{"balance", "/account/balance", func(c *Client) error {
+			_, err := c.GetBalance(context.Background(), &BalanceRequest{Provider: "pollinations"})
+			return err
+		}},
+		{"models", "/text/models", func(c *Client) error {
+			_, err := c.ListTextModels(context.Background(), &ModelsRequest{Provider: "pollinations"})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordedBytes{}
+			c := NewClient(WithHTTPClient(rewriteClient(srv)), WithMetricsRecorder(rec))
+			if err := tt.call(c); err != nil {
+				t.Fatalf("call: %v", err)
+			}
+			if want := len(bodies[tt.path]); len(rec.obs) != 1 || rec.obs[0] != want {
+				t.Errorf("observations = %v, want [%d]", rec.obs, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package llmclient
+
+import (
+	"context"
+	"net/http"
+)
+
+const defaultMistralURL = "https://api.mistral.ai/v1/chat/completions"
+
+// mistralProvider talks to La Plateforme. The body is OpenAI-shaped except
+// that the seed is called random_seed, stream_options is rejected,
+// frequency_penalty is rejected by some models and therefore never sent, and
+// safe_prompt switches on Mistral's guardrail prompt.
+type mistralProvider struct {
+	model      string
+	key        string
+	endpoint   string
+	client     *http.Client
+	header     http.Header
+	safePrompt *bool
+	chatOptions
+}
+
+type mistralPayload struct {
+	*chatPayload
+	RandomSeed *int  `json:"random_seed,omitempty"`
+	SafePrompt *bool `json:"safe_prompt,omitempty"`
+}
+
+func (p *mistralProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	respBody, err := postJSON(ctx, p.client, p.endpoint, p.payload(history, images, systemPrompt, false), p.key, p.header)
+	if err != nil {
+		return nil, err
+	}
+	return parseResponse(respBody)
+}
+
+func (p *mistralProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
+	return postJSONStream(ctx, p.client, p.endpoint, p.payload(history, images, systemPrompt, true), p.key, p.header, callback)
+}
+
+func (p *mistralProvider) payload(history []Message, images []string, systemPrompt string, stream bool) *mistralPayload {
+	payload := &mistralPayload{
+		chatPayload: p.newPayload(p.model, history, images, systemPrompt, stream),
+		SafePrompt:  p.safePrompt,
+	}
+	payload.RandomSeed, payload.Seed = payload.Seed, nil
+	payload.StreamOptions = nil
+	payload.FrequencyPenalty = nil
+	return payload
+}
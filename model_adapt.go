@@ -0,0 +1,83 @@
+package llmclient
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// WithAutoAdaptToModel looks the request's model up in the provider's model
+// list, fetched once per provider and cached, and drops the parameters it
+// would reject: sampling parameters for reasoning models and a max_tokens
+// larger than the context window. Only providers with a model list (built-in
+// for pollinations, or added with RegisterModelsProvider) are adapted; for
+// the rest, and for models their list does not know, requests are sent
+// unchanged.
+func WithAutoAdaptToModel() ClientOption {
+	return func(c *Client) { c.modelCatalog = &modelCatalog{models: make(map[string][]Model)} }
+}
+
+type modelCatalog struct {
+	mu     sync.Mutex
+	models map[string][]Model
+}
+
+// lookupModel takes the request after applyDefaults, so aliases and provider
+// default models are resolved. It runs before the provider slot is taken, as
+// ListTextModels needs one too.
+func (c *Client) lookupModel(ctx context.Context, req *Request) *Model {
+	if c.modelCatalog == nil || req.Model == "" {
+		return nil
+	}
+	model, _ := FindModel(c.modelCatalog.list(ctx, c, req), req.Model)
+	return model
+}
+
+// list caches the provider's models, and an empty list for providers that
+// have no models endpoint. A failed fetch is not cached, so it is retried on
+// the next request.
+func (mc *modelCatalog) list(ctx context.Context, c *Client, req *Request) []Model {
+	name := strings.ToLower(strings.TrimSpace(req.Provider))
+	mc.mu.Lock()
+	models, ok := mc.models[name]
+	mc.mu.Unlock()
+	if ok {
+		return models
+	}
+
+	modelsReq := &ModelsRequest{Provider: req.Provider, APIKey: req.APIKey}
+	if _, err := c.newModelsProvider(modelsReq); err != nil {
+		mc.store(name, nil)
+		return nil
+	}
+	resp, err := c.ListTextModels(ctx, modelsReq)
+	if err != nil {
+		return nil
+	}
+	mc.store(name, resp.Models)
+	return resp.Models
+}
+
+func (mc *modelCatalog) store(name string, models []Model) {
+	mc.mu.Lock()
+	mc.models[name] = models
+	mc.mu.Unlock()
+}
+
+// adaptToModel strips what model does not support from req, which must be
+// the copy made by applyDefaults.
+func adaptToModel(req *Request, model *Model) {
+	if model == nil {
+		return
+	}
+	if model.Reasoning {
+		req.Temperature = nil
+		req.TopP = nil
+		req.FrequencyPenalty = nil
+		req.PresencePenalty = nil
+	}
+	if model.ContextWindow > 0 && req.MaxTokens != nil && *req.MaxTokens > model.ContextWindow {
+		maxTokens := model.ContextWindow
+		req.MaxTokens = &maxTokens
+	}
+}
@@ -0,0 +1,136 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const adaptModelsJSON = `[
+	{"name":"deep","aliases":["deep-r1"],"reasoning":true,"context_window":4096},
+	{"name":"chat","context_window":1000}
+]`
+
+func TestAutoAdaptToModel(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		listCalls  int
+		lastParams map[string]interface{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/text/models" {
+			mu.Lock()
+			listCalls++
+			mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, adaptModelsJSON)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		payload := decodeBody(t, body)
+		params := map[string]interface{}{}
+		for _, key := range []string{"model", "temperature", "top_p", "presence_penalty", "max_tokens"} {
+			if v, ok := payload[key]; ok {
+				params[key] = v
+			}
+		}
+		mu.Lock()
+		lastParams = params
+		mu.Unlock()
+		if stream, _ := payload["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+deltaEvent("ok")+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	sampling := func(r *Request) {
+		WithTemperature(0.7)(r)
+		WithTopP(0.9)(r)
+		WithPresencePenalty(0.5)(r)
+		WithMaxTokens(8000)(r)
+	}
+	tests := []struct {
+		name  string
+		model string
+		opts  []ClientOption
+		want  map[string]interface{}
+	}{
+		{"reasoning model drops sampling", "deep", nil,
+			map[string]interface{}{"model": "deep", "max_tokens": 4096.0}},
+		{"list alias", "deep-r1", nil,
+			map[string]interface{}{"model": "deep-r1", "max_tokens": 4096.0}},
+		{"client alias", "thinker", []ClientOption{WithModelAliases(map[string]string{"thinker": "deep"})},
+			map[string]interface{}{"model": "deep", "max_tokens": 4096.0}},
+		{"provider default model", "", []ClientOption{WithProviderDefaults(map[string]string{"pollinations": "deep"})},
+			map[string]interface{}{"model": "deep", "max_tokens": 4096.0}},
+		{"regular model keeps sampling", "chat", nil,
+			map[string]interface{}{"model": "chat", "temperature": 0.7, "top_p": 0.9, "presence_penalty": 0.5, "max_tokens": 1000.0}},
+		{"unknown model is unchanged", "other", nil,
+			map[string]interface{}{"model": "other", "temperature": 0.7, "top_p": 0.9, "presence_penalty": 0.5, "max_tokens": 8000.0}},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				mu.Lock()
+				listCalls = 0
+				mu.Unlock()
+				// One slot per provider: the model list must be fetched
+				// without holding the slot the chat request waits for.
+				opts := append([]ClientOption{WithHTTPClient(rewriteClient(srv)), WithAutoAdaptToModel(), WithMaxConcurrent("pollinations", 1)}, tt.opts...)
+				c := NewClient(opts...)
+				for i := 0; i < 2; i++ {
+					req := &Request{Provider: "pollinations", Model: tt.model, Prompt: "hi"}
+					sampling(req)
+					var err error
+					if stream {
+						_, _, err = collectStream(t, c, req)
+					} else {
+						_, err = c.Send(context.Background(), req)
+					}
+					if err != nil {
+						t.Fatalf("request %d: %v", i+1, err)
+					}
+					mu.Lock()
+					got := lastParams
+					mu.Unlock()
+					if !reflect.DeepEqual(got, tt.want) {
+						t.Errorf("request %d params = %v, want %v", i+1, got, tt.want)
+					}
+				}
+				if listCalls != 1 {
+					t.Errorf("model list fetched %d times, want 1", listCalls)
+				}
+			})
+		}
+	}
+}
+
+func TestAutoAdaptToModelUnsupportedProvider(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	c := NewClient(WithAutoAdaptToModel())
+	for i := 0; i < 2; i++ {
+		req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}
+		WithTemperature(0.7)(req)
+		if _, err := c.Send(context.Background(), req); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if got := lastPayload()["temperature"]; got != 0.7 {
+			t.Errorf("temperature = %v, want it sent unchanged", got)
+		}
+	}
+	if models, ok := c.modelCatalog.models[srv.URL+"/v1/chat/completions"]; !ok || models != nil {
+		t.Errorf("catalog entry = %v (cached %v), want a cached empty list", models, ok)
+	}
+}
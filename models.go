@@ -32,6 +32,7 @@ type Model struct {
 	Reasoning        bool           `json:"reasoning,omitempty"`
 	IsSpecialized    bool           `json:"is_specialized,omitempty"`
 	PaidOnly         bool           `json:"paid_only,omitempty"`
+	Tier             string         `json:"tier,omitempty"`
 	ContextWindow    int            `json:"context_window,omitempty"`
 	Voices           []string       `json:"voices,omitempty"`
 	Metadata         map[string]any `json:"metadata,omitempty"`
@@ -39,8 +40,9 @@ type Model struct {
 }
 
 type ModelsResponse struct {
-	Models []Model `json:"models"`
-	Raw    []byte  `json:"-"`
+	Models  []Model           `json:"models"`
+	Headers map[string]string `json:"-"`
+	Raw     []byte            `json:"-"`
 }
 
 type ModelsRequest struct {
@@ -54,14 +56,21 @@ type AudioModelsRequest struct {
 }
 
 type AudioModelsResponse struct {
-	Models []Model `json:"models"`
-	Raw    []byte  `json:"-"`
+	Models  []Model           `json:"models"`
+	Headers map[string]string `json:"-"`
+	Raw     []byte            `json:"-"`
 }
 
 func (c *Client) ListTextModels(ctx context.Context, req *ModelsRequest) (*ModelsResponse, error) {
 	if req == nil {
 		return nil, errors.New("models request is nil")
 	}
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	provider, err := c.newModelsProvider(req)
 	if err != nil {
@@ -73,13 +82,20 @@ func (c *Client) ListTextModels(ctx context.Context, req *ModelsRequest) (*Model
 		return nil, err
 	}
 
-	return &ModelsResponse{Models: models, Raw: raw}, nil
+	c.observeResponseBytes(req.Provider, len(raw))
+	return &ModelsResponse{Models: models, Headers: captured.headers(), Raw: raw}, nil
 }
 
 func (c *Client) ListAudioModels(ctx context.Context, req *AudioModelsRequest) (*AudioModelsResponse, error) {
 	if req == nil {
 		return nil, errors.New("audio models request is nil")
 	}
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	provider, err := c.newAudioModelsProvider(req)
 	if err != nil {
@@ -91,7 +107,8 @@ func (c *Client) ListAudioModels(ctx context.Context, req *AudioModelsRequest) (
 		return nil, err
 	}
 
-	return &AudioModelsResponse{Models: models, Raw: raw}, nil
+	c.observeResponseBytes(req.Provider, len(raw))
+	return &AudioModelsResponse{Models: models, Headers: captured.headers(), Raw: raw}, nil
 }
 
 func (c *Client) newModelsProvider(req *ModelsRequest) (modelsProvider, error) {
@@ -172,17 +189,15 @@ func (p *pollinationsModelsProvider) ListModels(ctx context.Context, req *Models
 	}
 
 	if resp.StatusCode >= 300 {
-		return nil, nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(data))
+		return nil, nil, &APIError{StatusCode: resp.StatusCode, Body: string(data), Provider: req.Provider}
 	}
-
-	var models []Model
-	if err := json.Unmarshal(data, &models); err != nil {
-		return nil, nil, fmt.Errorf("parse response: %w", err)
+	if err := checkContentType(resp, data); err != nil {
+		return nil, nil, err
 	}
 
-	for i := range models {
-		models[i].Raw = make(map[string]any)
-		_ = json.Unmarshal(data, &models[i].Raw)
+	models, err := parsePollinationsModels(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse response: %w", err)
 	}
 
 	return models, data, nil
@@ -214,20 +229,110 @@ func (p *pollinationsAudioModelsProvider) ListAudioModels(ctx context.Context, r
 	}
 
 	if resp.StatusCode >= 300 {
-		return nil, nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(data))
+		return nil, nil, &APIError{StatusCode: resp.StatusCode, Body: string(data), Provider: req.Provider}
+	}
+	if err := checkContentType(resp, data); err != nil {
+		return nil, nil, err
 	}
 
-	var models []Model
-	if err := json.Unmarshal(data, &models); err != nil {
+	models, err := parsePollinationsModels(data)
+	if err != nil {
 		return nil, nil, fmt.Errorf("parse response: %w", err)
 	}
 
-	for i := range models {
-		models[i].Raw = make(map[string]any)
-		_ = json.Unmarshal(data, &models[i].Raw)
+	return models, data, nil
+}
+
+// pollinationsModel mirrors one entry of the Pollinations /text/models and
+// /audio/models listings. Older deployments use a different shape (tier,
+// vision/audio flags, voices nested under audio, a single alias string), so
+// both are accepted and normalised into Model.
+type pollinationsModel struct {
+	Name             string          `json:"name"`
+	Aliases          json.RawMessage `json:"aliases"`
+	Description      string          `json:"description"`
+	Pricing          *ModelPricing   `json:"pricing"`
+	InputModalities  []string        `json:"input_modalities"`
+	OutputModalities []string        `json:"output_modalities"`
+	Tools            bool            `json:"tools"`
+	Reasoning        bool            `json:"reasoning"`
+	IsSpecialized    bool            `json:"is_specialized"`
+	PaidOnly         bool            `json:"paid_only"`
+	Tier             string          `json:"tier"`
+	ContextWindow    int             `json:"context_window"`
+	ContextLength    int             `json:"contextLength"`
+	Voices           []string        `json:"voices"`
+	Vision           bool            `json:"vision"`
+	Audio            json.RawMessage `json:"audio"`
+}
+
+func parsePollinationsModels(data []byte) ([]Model, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
 	}
 
-	return models, data, nil
+	models := make([]Model, 0, len(entries))
+	for _, entry := range entries {
+		var pm pollinationsModel
+		if err := json.Unmarshal(entry, &pm); err != nil {
+			return nil, err
+		}
+		m := Model{
+			Name:             pm.Name,
+			Aliases:          pollinationsAliases(pm.Aliases),
+			Description:      pm.Description,
+			Pricing:          pm.Pricing,
+			InputModalities:  pm.InputModalities,
+			OutputModalities: pm.OutputModalities,
+			Tools:            pm.Tools,
+			Reasoning:        pm.Reasoning,
+			IsSpecialized:    pm.IsSpecialized,
+			PaidOnly:         pm.PaidOnly,
+			Tier:             pm.Tier,
+			ContextWindow:    pm.ContextWindow,
+			Voices:           pm.Voices,
+		}
+		if m.ContextWindow == 0 {
+			m.ContextWindow = pm.ContextLength
+		}
+		// Вложенный формат: "audio": {"voices": [...]}.
+		if len(m.Voices) == 0 && len(pm.Audio) > 0 {
+			var audio struct {
+				Voices []string `json:"voices"`
+			}
+			if json.Unmarshal(pm.Audio, &audio) == nil {
+				m.Voices = audio.Voices
+			}
+		}
+		if pm.Vision && !m.HasInputModality("image") {
+			if len(m.InputModalities) == 0 {
+				m.InputModalities = []string{"text"}
+			}
+			m.InputModalities = append(m.InputModalities, "image")
+		}
+		if !m.PaidOnly && m.Tier != "" && m.Tier != "anonymous" {
+			m.PaidOnly = true
+		}
+		_ = json.Unmarshal(entry, &m.Raw)
+		models = append(models, m)
+	}
+	return models, nil
+}
+
+func pollinationsAliases(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		return list
+	}
+	var single string
+	if json.Unmarshal(raw, &single) == nil && single != "" {
+		return []string{single}
+	}
+	return nil
 }
 
 func ListAudioModels(provider, apiKey string) ([]Model, error) {
@@ -289,6 +394,26 @@ func (m *Model) HasAlias(alias string) bool {
 	return false
 }
 
+// FindModel resolves a user-typed name against models, matching Name and
+// Aliases case-insensitively. The first match wins.
+func FindModel(models []Model, nameOrAlias string) (*Model, bool) {
+	nameOrAlias = strings.TrimSpace(nameOrAlias)
+	if nameOrAlias == "" {
+		return nil, false
+	}
+	for i := range models {
+		if strings.EqualFold(models[i].Name, nameOrAlias) {
+			return &models[i], true
+		}
+		for _, alias := range models[i].Aliases {
+			if strings.EqualFold(alias, nameOrAlias) {
+				return &models[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
 func (m *Model) EffectivePricePer1kTokens() float64 {
 	if m.Pricing == nil {
 		return 0
@@ -296,6 +421,29 @@ func (m *Model) EffectivePricePer1kTokens() float64 {
 	return (m.Pricing.PromptTextTokens + m.Pricing.CompletionTextTokens) * 1000
 }
 
+// EstimateCost prices a call at the model's per-token text rates, in
+// Pricing.Currency. Models without pricing cost 0.
+func (m *Model) EstimateCost(promptTokens, completionTokens int) float64 {
+	if m.Pricing == nil {
+		return 0
+	}
+	return float64(promptTokens)*m.Pricing.PromptTextTokens + float64(completionTokens)*m.Pricing.CompletionTextTokens
+}
+
+// EstimateCostForMessages is a pre-flight estimate of the prompt cost of
+// messages, counted with EstimateTokens. The completion is not included.
+func EstimateCostForMessages(models []Model, modelName string, messages []Message) (float64, error) {
+	model, ok := FindModel(models, modelName)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrModelNotFound, modelName)
+	}
+	promptTokens := 0
+	for _, m := range messages {
+		promptTokens += EstimateTokens(messageText(m))
+	}
+	return model.EstimateCost(promptTokens, 0), nil
+}
+
 func FilterModelsByModality(models []Model, inputModality, outputModality string) []Model {
 	var result []Model
 	for _, m := range models {
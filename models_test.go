@@ -0,0 +1,161 @@
+package llmclient
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFindModel(t *testing.T) {
+	models := []Model{
+		{Name: "gpt-4", Aliases: []string{"gpt4", "openai-large"}},
+		{Name: "mistral", Aliases: []string{"mistral-small"}},
+		{Name: "gpt4"},
+	}
+	tests := []struct {
+		name   string
+		lookup string
+		want   string
+		found  bool
+	}{
+		{"exact name", "mistral", "mistral", true},
+		{"name case-insensitive", "GPT-4", "gpt-4", true},
+		{"alias", "openai-large", "gpt-4", true},
+		{"alias case-insensitive", "Mistral-Small", "mistral", true},
+		{"first match wins", "gpt4", "gpt-4", true},
+		{"surrounding space", "  mistral ", "mistral", true},
+		{"no match", "claude", "", false},
+		{"empty", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := FindModel(models, tt.lookup)
+			if ok != tt.found {
+				t.Fatalf("FindModel(%q) found = %v, want %v", tt.lookup, ok, tt.found)
+			}
+			if !ok {
+				if got != nil {
+					t.Errorf("FindModel(%q) = %+v, want nil", tt.lookup, got)
+				}
+				return
+			}
+			if got.Name != tt.want {
+				t.Errorf("FindModel(%q) = %q, want %q", tt.lookup, got.Name, tt.want)
+			}
+			if got != &models[0] && got != &models[1] && got != &models[2] {
+				t.Errorf("FindModel(%q) returned a copy, want a pointer into models", tt.lookup)
+			}
+		})
+	}
+	if _, ok := FindModel(nil, "gpt-4"); ok {
+		t.Error("FindModel on an empty list found a model")
+	}
+}
+
+// pollinationsModelsJSON mixes the current /text/models shape with the older
+// one (tier, vision flag, single alias, contextLength, nested voices).
+const pollinationsModelsJSON = `[
+	{
+		"name": "openai",
+		"description": "OpenAI GPT-5 Nano",
+		"aliases": ["gpt-5-nano", "openai-fast"],
+		"pricing": {"currency": "pollen", "promptTextTokens": 0.055, "promptCachedTokens": 0.0055, "completionTextTokens": 0.44},
+		"input_modalities": ["text", "image"],
+		"output_modalities": ["text"],
+		"tools": true,
+		"reasoning": false,
+		"context_window": 128000,
+		"is_specialized": false
+	},
+	{
+		"name": "openai-audio",
+		"description": "OpenAI GPT-4o Mini Audio",
+		"aliases": "gpt-4o-mini-audio",
+		"tier": "seed",
+		"vision": true,
+		"contextLength": 32000,
+		"audio": {"voices": ["alloy", "echo"]}
+	},
+	{
+		"name": "evil",
+		"tier": "anonymous",
+		"uncensored": true
+	}
+]`
+
+func TestParsePollinationsModels(t *testing.T) {
+	models, err := parsePollinationsModels([]byte(pollinationsModelsJSON))
+	if err != nil {
+		t.Fatalf("parsePollinationsModels: %v", err)
+	}
+	for i := range models {
+		if models[i].Raw == nil {
+			t.Errorf("%s: Raw not kept", models[i].Name)
+		}
+		models[i].Raw = nil
+	}
+	want := []Model{
+		{
+			Name:             "openai",
+			Description:      "OpenAI GPT-5 Nano",
+			Aliases:          []string{"gpt-5-nano", "openai-fast"},
+			Pricing:          &ModelPricing{Currency: "pollen", PromptTextTokens: 0.055, PromptCachedTokens: 0.0055, CompletionTextTokens: 0.44},
+			InputModalities:  []string{"text", "image"},
+			OutputModalities: []string{"text"},
+			Tools:            true,
+			ContextWindow:    128000,
+		},
+		{
+			Name:            "openai-audio",
+			Description:     "OpenAI GPT-4o Mini Audio",
+			Aliases:         []string{"gpt-4o-mini-audio"},
+			Tier:            "seed",
+			PaidOnly:        true,
+			InputModalities: []string{"text", "image"},
+			ContextWindow:   32000,
+			Voices:          []string{"alloy", "echo"},
+		},
+		{Name: "evil", Tier: "anonymous"},
+	}
+	if !reflect.DeepEqual(models, want) {
+		t.Errorf("models = %+v\nwant %+v", models, want)
+	}
+
+	if _, err := parsePollinationsModels([]byte(`{"error":"down"}`)); err == nil {
+		t.Error("expected an error for a non-list body")
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	models, err := parsePollinationsModels([]byte(pollinationsModelsJSON))
+	if err != nil {
+		t.Fatalf("parsePollinationsModels: %v", err)
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+	priced, _ := FindModel(models, "openai")
+	if got := priced.EstimateCost(1000, 200); !near(got, 1000*0.055+200*0.44) {
+		t.Errorf("EstimateCost(1000, 200) = %v, want %v pollen", got, 1000*0.055+200*0.44)
+	}
+	if got := priced.EffectivePricePer1kTokens(); !near(got, (0.055+0.44)*1000) {
+		t.Errorf("EffectivePricePer1kTokens = %v", got)
+	}
+	unpriced, _ := FindModel(models, "evil")
+	if got := unpriced.EstimateCost(1000, 200); got != 0 {
+		t.Errorf("model without pricing costs %v, want 0", got)
+	}
+
+	messages := []Message{NewSystemMessage("Be brief."), NewUserMessage("What is the capital of France?")}
+	tokens := EstimateTokens("Be brief.") + EstimateTokens("What is the capital of France?")
+	got, err := EstimateCostForMessages(models, "openai-fast", messages)
+	if err != nil {
+		t.Fatalf("EstimateCostForMessages: %v", err)
+	}
+	if want := float64(tokens) * 0.055; !near(got, want) {
+		t.Errorf("EstimateCostForMessages = %v, want %v for %d prompt tokens", got, want, tokens)
+	}
+	if _, err := EstimateCostForMessages(models, "no-such-model", messages); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("err = %v, want ErrModelNotFound", err)
+	}
+}
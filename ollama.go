@@ -0,0 +1,166 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Ollama serves two chat APIs: the OpenAI-compatible /v1/chat/completions and
+// its native /api/chat. The native one takes sampling parameters inside an
+// "options" object, structured output as "format", images as raw base64 on
+// the message and streams newline-delimited JSON instead of SSE.
+
+func newOllamaProvider(endpoint string, req *Request, client *http.Client, header http.Header, opts chatOptions) *ollamaProvider {
+	native := isOllamaNativeEndpoint(endpoint)
+	if native {
+		opts.jsonSchemaSupported = true
+	}
+	return &ollamaProvider{model: req.Model, endpoint: endpoint, key: req.APIKey, client: client, header: header, native: native, chatOptions: opts}
+}
+
+func isOllamaNativeEndpoint(endpoint string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimRight(u.Path, "/"), "/api/chat")
+}
+
+type ollamaChatPayload struct {
+	Model    string                   `json:"model"`
+	Messages []map[string]interface{} `json:"messages"`
+	Stream   bool                     `json:"stream"`
+	Format   map[string]any           `json:"format,omitempty"`
+	Options  *ollamaOptions           `json:"options,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	NumPredict       *int     `json:"num_predict,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+}
+
+func (o *ollamaOptions) empty() bool {
+	return o.Temperature == nil && o.TopP == nil && o.FrequencyPenalty == nil && o.PresencePenalty == nil &&
+		o.NumPredict == nil && o.Seed == nil && len(o.Stop) == 0
+}
+
+func (p *ollamaProvider) nativePayload(history []Message, images []string, systemPrompt string, stream bool) *ollamaChatPayload {
+	payload := &ollamaChatPayload{
+		Model:    p.model,
+		Messages: ollamaMessages(history, images, systemPrompt),
+		Stream:   stream,
+	}
+	if p.jsonSchema != nil {
+		payload.Format = p.jsonSchema.Schema
+	}
+	options := &ollamaOptions{
+		Temperature:      p.temperature,
+		TopP:             p.topP,
+		FrequencyPenalty: p.frequencyPenalty,
+		PresencePenalty:  p.presencePenalty,
+		NumPredict:       p.maxTokens,
+		Seed:             p.seed,
+		Stop:             p.stop,
+	}
+	if !options.empty() {
+		payload.Options = options
+	}
+	return payload
+}
+
+func ollamaMessages(history []Message, images []string, systemPrompt string) []map[string]interface{} {
+	msgs := make([]map[string]interface{}, 0, len(history)+1)
+	if systemPrompt != "" {
+		msgs = append(msgs, map[string]interface{}{"role": "system", "content": systemPrompt})
+	}
+	for i, m := range history {
+		msg := map[string]interface{}{"role": m.Role, "content": messageText(m)}
+		var msgImages []string
+		for _, part := range m.ContentParts {
+			if part.Type == "image_url" && part.ImageURL != nil {
+				msgImages = append(msgImages, newImagePart(part.ImageURL).ollama())
+			}
+		}
+		if i == len(history)-1 && m.Role == "user" {
+			for _, img := range images {
+				msgImages = append(msgImages, imagePart{url: img}.ollama())
+			}
+		}
+		if len(msgImages) > 0 {
+			msg["images"] = msgImages
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+type ollamaChatResponse struct {
+	Model   string `json:"model"`
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int64  `json:"prompt_eval_count"`
+	EvalCount       int64  `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func parseOllamaResponse(body []byte) (*Response, error) {
+	var r ollamaChatResponse
+	if err := json.Unmarshal(body, &r); err != nil || r.Message.Content == "" {
+		return parseResponse(body)
+	}
+	return &Response{Content: r.Message.Content, Model: r.Model, FinishReason: r.DoneReason, Usage: r.usage(), Raw: body}, nil
+}
+
+// usage is reported only on the final, done response.
+func (r *ollamaChatResponse) usage() *TokenUsage {
+	if r.PromptEvalCount == 0 && r.EvalCount == 0 {
+		return nil
+	}
+	return &TokenUsage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+func parseOllamaStream(ctx context.Context, reader io.Reader, callback StreamCallback) error {
+	scanner := newStreamScanner(ctx, reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var r ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			continue
+		}
+		if r.Error != "" {
+			return errors.New(r.Error)
+		}
+
+		if r.Message.Content != "" {
+			if err := callback(StreamChunk{Content: r.Message.Content, Model: r.Model}); err != nil {
+				return err
+			}
+		}
+		if r.Done {
+			return callback(StreamChunk{Model: r.Model, Done: true, Usage: r.usage()})
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,158 @@
+package llmclient
+
+import "strings"
+
+// chatPayload is the OpenAI-shaped chat completion body shared by all
+// providers. Optional parameters are pointers with omitempty so that unset
+// options are left out of the JSON instead of being sent as zero values.
+type chatPayload struct {
+	Model            string                   `json:"model"`
+	Messages         []map[string]interface{} `json:"messages"`
+	Stream           bool                     `json:"stream"`
+	Temperature      *float64                 `json:"temperature,omitempty"`
+	TopP             *float64                 `json:"top_p,omitempty"`
+	FrequencyPenalty *float64                 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64                 `json:"presence_penalty,omitempty"`
+	MaxTokens        *int                     `json:"max_tokens,omitempty"`
+	Seed             *int                     `json:"seed,omitempty"`
+	Stop             []string                 `json:"stop,omitempty"`
+	StreamOptions    *streamOptions           `json:"stream_options,omitempty"`
+	ResponseFormat   *responseFormat          `json:"response_format,omitempty"`
+	Transforms       []string                 `json:"transforms,omitempty"`
+	Store            *bool                    `json:"store,omitempty"`
+	Metadata         map[string]string        `json:"metadata,omitempty"`
+	WebSearchOptions *webSearchOptions        `json:"web_search_options,omitempty"`
+	Tools            []map[string]interface{} `json:"tools,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// webSearchOptions is sent empty: its presence is what turns on the search
+// of OpenAI's search-preview models.
+type webSearchOptions struct{}
+
+// chatOptions carries the per-request generation options into providers.
+// It is embedded in every chat provider and builds their payloads.
+type chatOptions struct {
+	temperature         *float64
+	topP                *float64
+	frequencyPenalty    *float64
+	presencePenalty     *float64
+	maxTokens           *int
+	seed                *int
+	stop                []string
+	streamUsage         bool
+	store               *bool
+	metadata            map[string]string
+	jsonSchema          *JSONSchema
+	jsonSchemaSupported bool
+	storeSupported      bool // OpenAI's store and metadata
+	tools               []map[string]interface{}
+}
+
+func newChatOptions(req *Request) chatOptions {
+	return chatOptions{
+		temperature:      req.Temperature,
+		topP:             req.TopP,
+		frequencyPenalty: req.FrequencyPenalty,
+		presencePenalty:  req.PresencePenalty,
+		maxTokens:        req.MaxTokens,
+		seed:             req.Seed,
+		stop:             req.Stop,
+		streamUsage:      req.StreamUsage,
+		store:            req.Store,
+		metadata:         req.Metadata,
+		jsonSchema:       req.JSONSchema,
+		tools:            req.Tools,
+	}
+}
+
+func (o chatOptions) newPayload(model string, history []Message, images []string, systemPrompt string, stream bool) *chatPayload {
+	if o.jsonSchema != nil && !o.jsonSchemaSupported {
+		systemPrompt = appendSchemaInstruction(systemPrompt, o.jsonSchema)
+	}
+	payload := newChatPayload(model, history, images, systemPrompt, stream)
+	payload.Temperature = o.temperature
+	payload.TopP = o.topP
+	payload.FrequencyPenalty = o.frequencyPenalty
+	payload.PresencePenalty = o.presencePenalty
+	payload.MaxTokens = o.maxTokens
+	payload.Seed = o.seed
+	payload.Stop = o.stop
+	if stream && o.streamUsage {
+		payload.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+	payload.ResponseFormat = o.responseFormat()
+	if o.storeSupported {
+		payload.Store = o.store
+		payload.Metadata = o.metadata
+	}
+	payload.Tools = o.tools
+	return payload
+}
+
+// completionPayload is the legacy /v1/completions body: a single prompt
+// string instead of a message list.
+type completionPayload struct {
+	Model            string   `json:"model"`
+	Prompt           string   `json:"prompt"`
+	Stream           bool     `json:"stream"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+}
+
+func (o chatOptions) newCompletionPayload(model string, history []Message, systemPrompt string, stream bool) *completionPayload {
+	return &completionPayload{
+		Model:            model,
+		Prompt:           completionPrompt(history, systemPrompt),
+		Stream:           stream,
+		Temperature:      o.temperature,
+		TopP:             o.topP,
+		FrequencyPenalty: o.frequencyPenalty,
+		PresencePenalty:  o.presencePenalty,
+		MaxTokens:        o.maxTokens,
+		Seed:             o.seed,
+		Stop:             o.stop,
+	}
+}
+
+// completionPrompt flattens a chat history into a single prompt. A lone user
+// message is sent verbatim; anything longer becomes a role-labelled
+// transcript ending with an open assistant turn.
+func completionPrompt(history []Message, systemPrompt string) string {
+	if systemPrompt == "" && len(history) == 1 && history[0].Role == "user" {
+		return messageText(history[0])
+	}
+	var b strings.Builder
+	if systemPrompt != "" {
+		b.WriteString(systemPrompt)
+		b.WriteString("\n\n")
+	}
+	for _, m := range history {
+		role := m.Role
+		if role != "" {
+			role = strings.ToUpper(role[:1]) + role[1:]
+		}
+		b.WriteString(role)
+		b.WriteString(": ")
+		b.WriteString(messageText(m))
+		b.WriteString("\n")
+	}
+	b.WriteString("Assistant:")
+	return b.String()
+}
+
+func newChatPayload(model string, history []Message, images []string, systemPrompt string, stream bool) *chatPayload {
+	return &chatPayload{
+		Model:    model,
+		Messages: messagesToMaps(history, images, systemPrompt),
+		Stream:   stream,
+	}
+}
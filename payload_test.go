@@ -0,0 +1,549 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// samplingServer answers chat requests in whatever shape the caller asked
+// for and records each request body.
+func samplingServer(t *testing.T) (*httptest.Server, func() map[string]interface{}) {
+	var (
+		mu   sync.Mutex
+		last map[string]interface{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payload := decodeBody(t, body)
+		mu.Lock()
+		last = payload
+		mu.Unlock()
+		stream, _ := payload["stream"].(bool)
+		switch {
+		case r.URL.Path == "/api/chat":
+			io.WriteString(w, `{"model":"m","message":{"role":"assistant","content":"ok"},"done":true}`+"\n")
+		case stream:
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+deltaEvent("ok")+"\n\ndata: [DONE]\n\n")
+		default:
+			io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+func TestSamplingParamsInPayload(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+
+	providers := []struct {
+		name     string
+		provider string
+		endpoint string
+		options  bool // Ollama's native API nests them under "options"
+	}{
+		{"ollama", "ollama", srv.URL + "/v1/chat/completions", false},
+		{"ollama native", "ollama", srv.URL + "/api/chat", true},
+		{"openrouter", "openrouter", srv.URL + "/v1/chat/completions", false},
+		{"generic", srv.URL + "/v1/chat/completions", "", false},
+	}
+	for _, p := range providers {
+		for _, stream := range []bool{false, true} {
+			for _, set := range []bool{true, false} {
+				name := p.name
+				if stream {
+					name += "/stream"
+				}
+				if !set {
+					name += "/unset"
+				}
+				t.Run(name, func(t *testing.T) {
+					req := &Request{Provider: p.provider, Endpoint: p.endpoint, Model: "m", Prompt: "hi"}
+					if set {
+						WithTemperature(0.25)(req)
+						WithMaxTokens(64)(req)
+						WithSeed(7)(req)
+					}
+					var err error
+					if stream {
+						_, _, err = collectStream(t, NewClient(), req)
+					} else {
+						_, err = NewClient().Send(context.Background(), req)
+					}
+					if err != nil {
+						t.Fatalf("request: %v", err)
+					}
+
+					got := map[string]interface{}{}
+					params := lastPayload()
+					maxTokensKey := "max_tokens"
+					if p.options {
+						params, _ = params["options"].(map[string]interface{})
+						maxTokensKey = "num_predict"
+					}
+					for _, key := range []string{"temperature", maxTokensKey, "seed"} {
+						if v, ok := params[key]; ok {
+							got[key] = v
+						}
+					}
+
+					want := map[string]interface{}{}
+					if set {
+						want = map[string]interface{}{"temperature": 0.25, maxTokensKey: 64.0, "seed": 7.0}
+					}
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("sampling params = %v, want %v", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestSeedInPayload(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+
+	seed := func(n int) *int { return &n }
+	tests := []struct {
+		name     string
+		provider string
+		endpoint string
+		seed     *int
+	}{
+		{"openrouter", "openrouter", srv.URL + "/v1/chat/completions", seed(42)},
+		{"openrouter zero", "openrouter", srv.URL + "/v1/chat/completions", seed(0)},
+		{"openrouter unset", "openrouter", srv.URL + "/v1/chat/completions", nil},
+		{"generic", srv.URL + "/v1/chat/completions", "", seed(42)},
+		{"generic zero", srv.URL + "/v1/chat/completions", "", seed(0)},
+		{"generic unset", srv.URL + "/v1/chat/completions", "", nil},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				req := &Request{Provider: tt.provider, Endpoint: tt.endpoint, Model: "m", Prompt: "hi", Seed: tt.seed}
+				var err error
+				if stream {
+					_, _, err = collectStream(t, NewClient(), req)
+				} else {
+					_, err = NewClient().Send(context.Background(), req)
+				}
+				if err != nil {
+					t.Fatalf("request: %v", err)
+				}
+
+				got, ok := lastPayload()["seed"]
+				switch {
+				case tt.seed == nil && ok:
+					t.Errorf("seed = %v sent without WithSeed", got)
+				case tt.seed != nil && got != float64(*tt.seed):
+					t.Errorf("seed = %v (present %v), want %d", got, ok, *tt.seed)
+				}
+			})
+		}
+	}
+}
+
+func TestContentPartsInNonUserRoles(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	chart := "https://example.com/chart.png"
+	req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Messages: []Message{
+		NewMessageWithContentParts("system", []ContentPart{NewTextPart("Analyse this chart"), NewImageURLPartWithDetail(chart, "high")}),
+		NewToolMessageWithContentParts("call_1", []ContentPart{NewTextPart("rendered"), NewImageURLPart(chart)}),
+		NewUserMessage("What is the trend?"),
+	}}
+	if _, err := NewClient().Send(context.Background(), req); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	want := []interface{}{
+		map[string]interface{}{"role": "system", "content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "Analyse this chart"},
+			map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": chart, "detail": "high"}},
+		}},
+		map[string]interface{}{"role": "tool", "tool_call_id": "call_1", "content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "rendered"},
+			map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": chart}},
+		}},
+		map[string]interface{}{"role": "user", "content": "What is the trend?"},
+	}
+	if got := lastPayload()["messages"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %v\nwant %v", got, want)
+	}
+}
+
+func TestLegacyCompletion(t *testing.T) {
+	var (
+		path    string
+		payload map[string]interface{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		path, payload = r.URL.Path, decodeBody(t, body)
+		if stream, _ := payload["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"choices\":[{\"text\":\"Hel\",\"index\":0}]}\n\n")
+			io.WriteString(w, "data: {\"choices\":[{\"text\":\"lo\",\"index\":0,\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"object":"text_completion","choices":[{"text":"Hello","index":0,"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	for _, stream := range []bool{false, true} {
+		req := &Request{Provider: srv.URL + "/v1/completions", Model: "m", Prompt: "Say hello"}
+		WithCompletion()(req)
+		WithMaxTokens(16)(req)
+		var content string
+		if stream {
+			_, resp, err := collectStream(t, NewClient(), req)
+			if err != nil {
+				t.Fatalf("SendStream: %v", err)
+			}
+			content = resp.Content
+		} else {
+			resp, err := NewClient().Send(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			content = resp.Content
+		}
+		if content != "Hello" {
+			t.Errorf("stream %v: content = %q, want Hello", stream, content)
+		}
+		if path != "/v1/completions" {
+			t.Errorf("stream %v: path = %q", stream, path)
+		}
+		want := map[string]interface{}{"model": "m", "prompt": "Say hello", "stream": stream, "max_tokens": 16.0}
+		if !reflect.DeepEqual(payload, want) {
+			t.Errorf("stream %v: payload = %v, want %v", stream, payload, want)
+		}
+	}
+}
+
+func TestImageToolResultInHistory(t *testing.T) {
+	var payload map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payload = decodeBody(t, body)
+		if r.URL.Path == "/v1/messages" {
+			io.WriteString(w, `{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	chart := "data:image/png;base64,iVBORw0KGgo="
+	history := []Message{
+		NewUserMessage("Plot sales"),
+		NewAssistantToolCallsMessage("", []ToolCall{{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "plot", Arguments: `{"series":"sales"}`}}}),
+		NewToolMessageWithContentParts("call_1", []ContentPart{NewTextPart("chart"), NewImageURLPart(chart)}),
+		NewUserMessage("Describe the trend"),
+	}
+
+	t.Run("openai", func(t *testing.T) {
+		req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Messages: history}
+		if _, err := NewClient().Send(context.Background(), req); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		want := []interface{}{
+			map[string]interface{}{"role": "user", "content": "Plot sales"},
+			map[string]interface{}{"role": "assistant", "content": nil, "tool_calls": []interface{}{
+				map[string]interface{}{"id": "call_1", "type": "function",
+					"function": map[string]interface{}{"name": "plot", "arguments": `{"series":"sales"}`}},
+			}},
+			map[string]interface{}{"role": "tool", "tool_call_id": "call_1", "content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "chart"},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": chart}},
+			}},
+			map[string]interface{}{"role": "user", "content": "Describe the trend"},
+		}
+		if got := payload["messages"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("messages = %v\nwant %v", got, want)
+		}
+	})
+
+	t.Run("anthropic", func(t *testing.T) {
+		req := &Request{Provider: "anthropic", Endpoint: srv.URL + "/v1/messages", Model: "claude", Messages: history}
+		if _, err := NewClient().Send(context.Background(), req); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		want := []interface{}{
+			map[string]interface{}{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Plot sales"},
+			}},
+			map[string]interface{}{"role": "assistant", "content": []interface{}{
+				map[string]interface{}{"type": "tool_use", "id": "call_1", "name": "plot",
+					"input": map[string]interface{}{"series": "sales"}},
+			}},
+			map[string]interface{}{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "tool_result", "tool_use_id": "call_1", "content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "chart"},
+					map[string]interface{}{"type": "image", "source": map[string]interface{}{
+						"type": "base64", "media_type": "image/png", "data": "iVBORw0KGgo="}},
+				}},
+			}},
+			map[string]interface{}{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Describe the trend"},
+			}},
+		}
+		if got := payload["messages"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("messages = %v\nwant %v", got, want)
+		}
+	})
+}
+
+func TestOpenRouterTransformsInPayload(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	tests := []struct {
+		name       string
+		provider   string
+		transforms []string
+		want       interface{}
+	}{
+		{"openrouter", "openrouter", []string{"middle-out"}, []interface{}{"middle-out"}},
+		{"openrouter unset", "openrouter", nil, nil},
+		{"other providers ignore it", "openai", []string{"middle-out"}, nil},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			req := &Request{Provider: tt.provider, Endpoint: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}
+			if tt.transforms != nil {
+				WithOpenRouterTransforms(tt.transforms...)(req)
+			}
+			var err error
+			if stream {
+				_, _, err = collectStream(t, NewClient(), req)
+			} else {
+				_, err = NewClient().Send(context.Background(), req)
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if got := lastPayload()["transforms"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s (stream %v): transforms = %v, want %v", tt.name, stream, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestStopInPayload(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+
+	providers := []struct {
+		name     string
+		provider string
+		endpoint string
+		options  bool
+	}{
+		{"openrouter", "openrouter", srv.URL + "/v1/chat/completions", false},
+		{"generic", srv.URL + "/v1/chat/completions", "", false},
+		{"ollama native", "ollama", srv.URL + "/api/chat", true},
+	}
+	tests := []struct {
+		name string
+		stop []string
+		want interface{} // nil: the key must be absent
+	}{
+		{"single", []string{"END"}, []interface{}{"END"}},
+		{"multiple", []string{"END", "\n\n"}, []interface{}{"END", "\n\n"}},
+		{"empty", []string{}, nil},
+	}
+	for _, p := range providers {
+		for _, tt := range tests {
+			t.Run(p.name+"/"+tt.name, func(t *testing.T) {
+				req := &Request{Provider: p.provider, Endpoint: p.endpoint, Model: "m", Prompt: "hi"}
+				WithStop(tt.stop...)(req)
+				if _, err := NewClient().Send(context.Background(), req); err != nil {
+					t.Fatalf("Send: %v", err)
+				}
+				params := lastPayload()
+				if p.options {
+					params, _ = params["options"].(map[string]interface{})
+				}
+				got, ok := params["stop"]
+				if tt.want == nil {
+					if ok {
+						t.Errorf("stop = %v, want it omitted", got)
+					}
+					return
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("stop = %#v, want %#v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestClientSamplingDefaults(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	c := NewClient(WithDefaultTemperature(0.5), WithDefaultTopP(0.9), WithDefaultMaxTokens(128))
+
+	tests := []struct {
+		name string
+		opts []SendOption
+		want map[string]interface{}
+	}{
+		{"defaults", nil, map[string]interface{}{"temperature": 0.5, "top_p": 0.9, "max_tokens": 128.0}},
+		{"request overrides", []SendOption{WithTemperature(0), WithMaxTokens(16)}, map[string]interface{}{"temperature": 0.0, "top_p": 0.9, "max_tokens": 16.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Provider: "openrouter", Endpoint: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}
+			for _, opt := range tt.opts {
+				opt(req)
+			}
+			if _, err := c.Send(context.Background(), req); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			payload := lastPayload()
+			got := map[string]interface{}{}
+			for key := range tt.want {
+				got[key] = payload[key]
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sampling params = %v, want %v", got, tt.want)
+			}
+			if req.Temperature != nil && tt.opts == nil {
+				t.Error("defaults were written back into the caller's request")
+			}
+		})
+	}
+}
+
+func TestMistralPayload(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	for _, stream := range []bool{false, true} {
+		req := &Request{Provider: "mistral", Endpoint: srv.URL + "/v1/chat/completions", Model: "mistral-small-latest", Prompt: "hi"}
+		for _, opt := range []SendOption{
+			WithSeed(7), WithSafePrompt(true), WithFrequencyPenalty(0.5), WithPresencePenalty(0.25),
+			WithStore(true), WithMetadata(map[string]string{"user": "u1"}), WithStreamUsage(),
+		} {
+			opt(req)
+		}
+		var err error
+		if stream {
+			_, _, err = collectStream(t, NewClient(), req)
+		} else {
+			_, err = NewClient().Send(context.Background(), req)
+		}
+		if err != nil {
+			t.Fatalf("stream %v: %v", stream, err)
+		}
+
+		payload := lastPayload()
+		want := map[string]interface{}{"random_seed": 7.0, "safe_prompt": true, "presence_penalty": 0.25, "stream": stream}
+		for key, v := range want {
+			if payload[key] != v {
+				t.Errorf("stream %v: %s = %v, want %v", stream, key, payload[key], v)
+			}
+		}
+		for _, key := range []string{"seed", "frequency_penalty", "stream_options", "store", "metadata"} {
+			if v, ok := payload[key]; ok {
+				t.Errorf("stream %v: %s = %v sent to Mistral", stream, key, v)
+			}
+		}
+	}
+}
+
+func TestStoreMetadataInPayload(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	tests := []struct {
+		name     string
+		provider string
+		endpoint string
+		sent     bool
+	}{
+		{"openai", "openai", srv.URL + "/v1/chat/completions", true},
+		{"openrouter", "openrouter", srv.URL + "/v1/chat/completions", true},
+		{"pollinations", "pollinations", srv.URL + "/v1/chat/completions", false},
+		{"groq", "groq", srv.URL + "/v1/chat/completions", false},
+		{"ollama", "ollama", srv.URL + "/v1/chat/completions", false},
+		{"ollama native", "ollama", srv.URL + "/api/chat", false},
+		{"mistral", "mistral", srv.URL + "/v1/chat/completions", false},
+		{"generic", srv.URL + "/v1/chat/completions", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Provider: tt.provider, Endpoint: tt.endpoint, Model: "m", Prompt: "hi"}
+			WithStore(true)(req)
+			WithMetadata(map[string]string{"user": "u1"})(req)
+			if _, err := NewClient().Send(context.Background(), req); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			payload := lastPayload()
+			store, hasStore := payload["store"]
+			metadata, hasMetadata := payload["metadata"]
+			if !tt.sent {
+				if hasStore || hasMetadata {
+					t.Errorf("store = %v, metadata = %v sent to %s", store, metadata, tt.name)
+				}
+				return
+			}
+			if store != true || !reflect.DeepEqual(metadata, map[string]interface{}{"user": "u1"}) {
+				t.Errorf("store = %v, metadata = %v", store, metadata)
+			}
+		})
+	}
+}
+
+func TestWebSearchInPayload(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	tests := []struct {
+		name        string
+		provider    string
+		model       string
+		wantModel   string
+		wantOptions bool
+	}{
+		{"openrouter", "openrouter", "openai/gpt-4o", "openai/gpt-4o:online", false},
+		{"openrouter already online", "openrouter", "openai/gpt-4o:online", "openai/gpt-4o:online", false},
+		{"openai", "openai", "gpt-4o-search-preview", "gpt-4o-search-preview", true},
+		{"groq ignores it", "groq", "llama-3.1-8b-instant", "llama-3.1-8b-instant", false},
+		{"mistral ignores it", "mistral", "mistral-small-latest", "mistral-small-latest", false},
+		{"pollinations ignores it", "pollinations", "openai", "openai", false},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				req := &Request{Provider: tt.provider, Endpoint: srv.URL + "/v1/chat/completions", Model: tt.model, Prompt: "news?"}
+				WithWebSearch()(req)
+				var err error
+				if stream {
+					_, _, err = collectStream(t, NewClient(), req)
+				} else {
+					_, err = NewClient().Send(context.Background(), req)
+				}
+				if err != nil {
+					t.Fatalf("request: %v", err)
+				}
+				payload := lastPayload()
+				if payload["model"] != tt.wantModel {
+					t.Errorf("model = %v, want %q", payload["model"], tt.wantModel)
+				}
+				options, ok := payload["web_search_options"]
+				if ok != tt.wantOptions || (ok && !reflect.DeepEqual(options, map[string]interface{}{})) {
+					t.Errorf("web_search_options = %v (present %v), want present %v", options, ok, tt.wantOptions)
+				}
+			})
+		}
+	}
+}
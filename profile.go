@@ -44,6 +44,7 @@ type ProfileLimits struct {
 
 type ProfileResponse struct {
 	Profile *Profile
+	Headers map[string]string
 	Raw     []byte
 }
 
@@ -68,6 +69,12 @@ func (c *Client) GetProfile(ctx context.Context, req *ProfileRequest) (*ProfileR
 	if req == nil {
 		return nil, fmt.Errorf("profile request is nil")
 	}
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	provider, err := c.newProfileProvider(req)
 	if err != nil {
@@ -79,7 +86,8 @@ func (c *Client) GetProfile(ctx context.Context, req *ProfileRequest) (*ProfileR
 		return nil, err
 	}
 
-	return &ProfileResponse{Profile: profile, Raw: raw}, nil
+	c.observeResponseBytes(req.Provider, len(raw))
+	return &ProfileResponse{Profile: profile, Headers: captured.headers(), Raw: raw}, nil
 }
 
 func (c *Client) newProfileProvider(req *ProfileRequest) (profileProvider, error) {
@@ -122,7 +130,10 @@ func (p *pollinationsProfileProvider) GetProfile(ctx context.Context, req *Profi
 	}
 
 	if resp.StatusCode >= 300 {
-		return nil, nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(data))
+		return nil, nil, &APIError{StatusCode: resp.StatusCode, Body: string(data), Provider: req.Provider}
+	}
+	if err := checkContentType(resp, data); err != nil {
+		return nil, nil, err
 	}
 
 	var profile Profile
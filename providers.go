@@ -0,0 +1,127 @@
+package llmclient
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ChatProvider sends one chat completion: the conversation history, images
+// attached to the last user turn and the system prompt.
+type ChatProvider interface {
+	Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error)
+}
+
+// StreamingChatProvider is a ChatProvider that SendStream can use.
+type StreamingChatProvider interface {
+	ChatProvider
+	SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error
+}
+
+type ChatProviderFactory func(c *Client, req *Request) ChatProvider
+
+// registeredChatProviders holds the built-in providers and anything added
+// through RegisterChatProvider, keyed by lower-case name.
+var (
+	registeredChatProvidersMu sync.RWMutex
+	registeredChatProviders   = map[string]ChatProviderFactory{
+		"ollama":       newOllamaChatProvider,
+		"pollinations": newPollinationsChatProvider,
+		"openrouter":   newOpenRouterChatProvider,
+		"anthropic":    newAnthropicChatProvider,
+		"openai":       newOpenAIChatProvider,
+		"groq":         newGroqChatProvider,
+		"mistral":      newMistralChatProvider,
+	}
+)
+
+// RegisterChatProvider adds a chat provider under name, or replaces a
+// built-in one. It may be called while requests are in flight.
+func RegisterChatProvider(name string, factory ChatProviderFactory) {
+	registeredChatProvidersMu.Lock()
+	defer registeredChatProvidersMu.Unlock()
+	registeredChatProviders[strings.ToLower(strings.TrimSpace(name))] = factory
+}
+
+func chatProviderFactory(name string) (ChatProviderFactory, bool) {
+	registeredChatProvidersMu.RLock()
+	defer registeredChatProvidersMu.RUnlock()
+	factory, ok := registeredChatProviders[name]
+	return factory, ok
+}
+
+func (c *Client) newProvider(req *Request) (ChatProvider, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Provider))
+	if factory, ok := chatProviderFactory(name); ok {
+		return factory(c, req), nil
+	}
+
+	header := requestHeader(req)
+	opts := newChatOptions(req)
+	opts.jsonSchemaSupported = true
+	endpoint := c.endpointFor(name, req)
+	if isURL(name) {
+		endpoint = name
+	}
+	if !isURL(endpoint) {
+		return nil, fmt.Errorf("unknown provider: %s", req.Provider)
+	}
+	if isOllamaNativeEndpoint(endpoint) {
+		return newOllamaProvider(endpoint, req, c.httpClient, header, opts), nil
+	}
+	return &genericProvider{endpoint: endpoint, model: req.Model, key: req.APIKey, client: c.httpClient, header: header, completion: req.Completion, chatOptions: opts}, nil
+}
+
+// endpointFor picks the chat URL override for a provider: the request's own
+// Endpoint first, then the client's WithEndpoints map.
+func (c *Client) endpointFor(name string, req *Request) string {
+	if req.Endpoint != "" {
+		return req.Endpoint
+	}
+	return c.endpoints[name]
+}
+
+// builtinEndpoint is endpointFor with the provider's default URL.
+func (c *Client) builtinEndpoint(name string, req *Request, fallback string) string {
+	if endpoint := c.endpointFor(name, req); endpoint != "" {
+		return endpoint
+	}
+	return fallback
+}
+
+func newOllamaChatProvider(c *Client, req *Request) ChatProvider {
+	return newOllamaProvider(c.builtinEndpoint("ollama", req, defaultOllamaURL), req, c.httpClient, requestHeader(req), newChatOptions(req))
+}
+
+func newPollinationsChatProvider(c *Client, req *Request) ChatProvider {
+	return &pollinationsProvider{model: req.Model, key: req.APIKey, url: c.endpointFor("pollinations", req), client: c.httpClient, header: requestHeader(req), chatOptions: newChatOptions(req)}
+}
+
+func newOpenRouterChatProvider(c *Client, req *Request) ChatProvider {
+	opts := newChatOptions(req)
+	opts.jsonSchemaSupported = true
+	opts.storeSupported = true
+	return &openRouterProvider{model: req.Model, key: req.APIKey, endpoint: c.builtinEndpoint("openrouter", req, defaultOpenRouterURL), client: c.httpClient, header: requestHeader(req), transforms: req.OpenRouterTransforms, webSearch: req.WebSearch, chatOptions: opts}
+}
+
+func newAnthropicChatProvider(c *Client, req *Request) ChatProvider {
+	return &anthropicProvider{model: req.Model, key: req.APIKey, endpoint: c.builtinEndpoint("anthropic", req, defaultAnthropicURL), client: c.httpClient, header: requestHeader(req), cacheTTL: req.PromptCacheTTL, chatOptions: newChatOptions(req)}
+}
+
+func newOpenAIChatProvider(c *Client, req *Request) ChatProvider {
+	opts := newChatOptions(req)
+	opts.jsonSchemaSupported = true
+	opts.storeSupported = true
+	return &openAIProvider{model: req.Model, key: req.APIKey, endpoint: c.builtinEndpoint("openai", req, defaultOpenAIURL), client: c.httpClient, header: requestHeader(req), webSearch: req.WebSearch, chatOptions: opts}
+}
+
+func newGroqChatProvider(c *Client, req *Request) ChatProvider {
+	return &groqProvider{model: req.Model, key: req.APIKey, endpoint: c.builtinEndpoint("groq", req, defaultGroqURL), client: c.httpClient, header: requestHeader(req), chatOptions: newChatOptions(req)}
+}
+
+func newMistralChatProvider(c *Client, req *Request) ChatProvider {
+	opts := newChatOptions(req)
+	opts.jsonSchemaSupported = true
+	return &mistralProvider{model: req.Model, key: req.APIKey, endpoint: c.builtinEndpoint("mistral", req, defaultMistralURL), client: c.httpClient, header: requestHeader(req), safePrompt: req.SafePrompt, chatOptions: opts}
+}
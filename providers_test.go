@@ -0,0 +1,437 @@
+package llmclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPollinationsEndpointByKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		stream  bool
+		wantURL string
+	}{
+		{"send without key", "", false, pollinationsFreeURL},
+		{"send with key", "sk", false, pollinationsPaidURL},
+		{"stream without key", "", true, pollinationsFreeURL},
+		{"stream with key", "sk", true, pollinationsPaidURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotURL string
+			hc := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				gotURL = req.URL.String()
+				if tt.stream {
+					return cannedResponse(req, 200, "text/event-stream", "data: "+deltaEvent("ok")+"\n\ndata: [DONE]\n\n"), nil
+				}
+				return cannedResponse(req, 200, "application/json", `{"choices":[{"message":{"content":"ok"}}]}`), nil
+			})}
+			c := NewClient(WithHTTPClient(hc))
+			req := &Request{Provider: "pollinations", Model: "openai", APIKey: tt.key, Prompt: "hi"}
+
+			var err error
+			if tt.stream {
+				_, err = c.SendStream(context.Background(), req, func(StreamChunk) error { return nil })
+			} else {
+				_, err = c.Send(context.Background(), req)
+			}
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if gotURL != tt.wantURL {
+				t.Errorf("URL = %s, want %s", gotURL, tt.wantURL)
+			}
+		})
+	}
+}
+
+type echoChatProvider struct{ model string }
+
+func (p *echoChatProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	return &Response{Content: "echo: " + messageText(history[len(history)-1]), Model: p.model}, nil
+}
+
+type echoStreamProvider struct{ echoChatProvider }
+
+func (p *echoStreamProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
+	if err := callback(StreamChunk{Content: "echo: " + messageText(history[len(history)-1]), Model: p.model}); err != nil {
+		return err
+	}
+	return callback(StreamChunk{Model: p.model, Done: true})
+}
+
+// registerTestChatProvider registers factory for the duration of the test.
+func registerTestChatProvider(t *testing.T, name string, factory ChatProviderFactory) {
+	RegisterChatProvider(name, factory)
+	t.Cleanup(func() {
+		registeredChatProvidersMu.Lock()
+		delete(registeredChatProviders, name)
+		registeredChatProvidersMu.Unlock()
+	})
+}
+
+func TestRegisterChatProvider(t *testing.T) {
+	registerTestChatProvider(t, "test-echo", func(c *Client, req *Request) ChatProvider {
+		return &echoChatProvider{model: req.Model}
+	})
+	registerTestChatProvider(t, "test-echo-stream", func(c *Client, req *Request) ChatProvider {
+		return &echoStreamProvider{echoChatProvider{model: req.Model}}
+	})
+
+	tests := []struct {
+		provider   string
+		stream     bool
+		wantStream bool
+	}{
+		{"test-echo", false, false},
+		{" Test-Echo ", false, false},
+		{"test-echo", true, false},
+		{"test-echo-stream", false, false},
+		{"test-echo-stream", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/stream=%v", tt.provider, tt.stream), func(t *testing.T) {
+			req := &Request{Provider: tt.provider, Model: "m", Prompt: "hi"}
+			var (
+				content string
+				err     error
+			)
+			if tt.stream {
+				var chunks []StreamChunk
+				var resp *StreamResponse
+				chunks, resp, err = collectStream(t, NewClient(), req)
+				if err == nil {
+					content = resp.Content
+					if tt.wantStream && len(chunks) != 2 {
+						t.Errorf("got %d chunks, want the provider's 2", len(chunks))
+					}
+				}
+			} else {
+				var resp *Response
+				resp, err = NewClient().Send(context.Background(), req)
+				if err == nil {
+					content = resp.Content
+				}
+			}
+			if tt.stream && !tt.wantStream {
+				if err == nil {
+					t.Fatal("expected an error streaming through a provider without SendStream")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if content != "echo: hi" {
+				t.Errorf("content = %q", content)
+			}
+		})
+	}
+}
+
+// httpChatProvider posts the last user turn to url through client.
+type httpChatProvider struct {
+	client *http.Client
+	url    string
+}
+
+func (p *httpChatProvider) Send(ctx context.Context, history []Message, images []string, systemPrompt string) (*Response, error) {
+	body, err := postJSON(ctx, p.client, p.url, map[string]string{"prompt": messageText(history[len(history)-1])}, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseResponse(body)
+}
+
+func TestRegisteredProviderUsesClientTransport(t *testing.T) {
+	srv, calls := flakyServer(t, 1, http.StatusServiceUnavailable, `{"choices":[{"message":{"content":"ok"}}]}`)
+	registerTestChatProvider(t, "test-http", func(c *Client, req *Request) ChatProvider {
+		return &httpChatProvider{client: c.HTTPClient(), url: srv.URL + "/gateway"}
+	})
+
+	var retried []int
+	c := NewClient(WithRetry(3, time.Millisecond), WithOnRetry(func(attempt int, err error, nextDelay time.Duration) {
+		retried = append(retried, attempt)
+	}))
+	resp, err := c.Send(context.Background(), &Request{Provider: "test-http", Model: "m", Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if resp.Content != "ok" {
+		t.Errorf("content = %q", resp.Content)
+	}
+	if got := calls("/gateway"); got != 2 || !reflect.DeepEqual(retried, []int{1}) {
+		t.Errorf("calls = %d, retries = %v; want the 503 retried once", got, retried)
+	}
+}
+
+func TestRegisterChatProviderConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("test-concurrent-%d", i)
+		registerTestChatProvider(t, name, func(c *Client, req *Request) ChatProvider { return &echoChatProvider{} })
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterChatProvider(name, func(c *Client, req *Request) ChatProvider { return &echoChatProvider{model: name} })
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := NewClient().Send(context.Background(), &Request{Provider: "openai", Model: "m", Prompt: "hi", Endpoint: "http://127.0.0.1:0"}); err == nil {
+				t.Error("expected a connection error")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestImageResponseURL(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		paths  []string
+		format interface{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/v1/images/generations":
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			format = decodeBody(t, body)["response_format"]
+			mu.Unlock()
+			io.WriteString(w, `{"data":[{"url":"http://`+r.Host+`/files/cat.png"}]}`)
+		case "/files/cat.png":
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, "\x89PNG\r\n\x1a\ncat")
+		default:
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, "\x89PNG\r\n\x1a\npollinations")
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		provider   string
+		format     ImageResponseFormat
+		wantURL    string
+		wantData   bool
+		wantPaths  []string
+		wantFormat interface{}
+	}{
+		{"GET provider URL", "pollinations", ImageResponseURL,
+			"https://gen.pollinations.ai/image/a%20cat?model=flux", false, nil, nil},
+		{"GET provider bytes", "pollinations", ImageResponseBytes,
+			"https://gen.pollinations.ai/image/a%20cat?model=flux", true, []string{"/image/a cat"}, nil},
+		{"POST provider URL", srv.URL + "/v1/images/generations", ImageResponseURL,
+			srv.URL + "/files/cat.png", false, []string{"/v1/images/generations"}, "url"},
+		{"POST provider bytes from URL", srv.URL + "/v1/images/generations", ImageResponseBytes,
+			srv.URL + "/files/cat.png", true, []string{"/v1/images/generations", "/files/cat.png"}, "b64_json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			paths, format = nil, nil
+			mu.Unlock()
+			c := NewClient(WithHTTPClient(rewriteClient(srv)))
+			resp, err := c.GenerateImage(context.Background(), &ImageRequest{Provider: tt.provider, Model: "flux", Prompt: "a cat", ResponseFormat: tt.format})
+			if err != nil {
+				t.Fatalf("GenerateImage: %v", err)
+			}
+			if resp.URL != tt.wantURL {
+				t.Errorf("URL = %q, want %q", resp.URL, tt.wantURL)
+			}
+			if got := len(resp.Data) > 0; got != tt.wantData {
+				t.Errorf("has data = %v, want %v", got, tt.wantData)
+			} else if tt.wantData && !strings.HasPrefix(string(resp.Data), "\x89PNG") {
+				t.Errorf("data = %q", resp.Data)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if fmt.Sprint(paths) != fmt.Sprint(tt.wantPaths) {
+				t.Errorf("requests = %v, want %v", paths, tt.wantPaths)
+			}
+			if format != tt.wantFormat {
+				t.Errorf("response_format = %v, want %v", format, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestEndpointOverrides(t *testing.T) {
+	newServer := func(name string, hits *[]string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*hits = append(*hits, name+" "+r.URL.Path)
+			if r.URL.Path == "/api/chat" {
+				io.WriteString(w, `{"message":{"role":"assistant","content":"ok"},"done":true}`+"\n")
+				return
+			}
+			io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	var hits []string
+	client := newServer("client", &hits)
+	request := newServer("request", &hits)
+
+	c := NewClient(WithEndpoints(map[string]string{
+		" OpenRouter ": client.URL + "/v1/chat/completions",
+		"ollama":       client.URL + "/api/chat",
+	}))
+	tests := []struct {
+		name, provider, endpoint, want string
+	}{
+		{"client override", "openrouter", "", "client /v1/chat/completions"},
+		{"native ollama override", "ollama", "", "client /api/chat"},
+		{"request endpoint wins", "openrouter", request.URL + "/custom", "request /custom"},
+		{"request endpoint wins for ollama", "ollama", request.URL + "/v1/chat/completions", "request /v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits = nil
+			req := &Request{Provider: tt.provider, Endpoint: tt.endpoint, Model: "m", Prompt: "hi"}
+			if got := c.endpointFor(tt.provider, req); tt.endpoint != "" && got != tt.endpoint {
+				t.Errorf("endpointFor = %q, want %q", got, tt.endpoint)
+			}
+			if _, err := c.Send(context.Background(), req); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if len(hits) != 1 || hits[0] != tt.want {
+				t.Errorf("requests = %q, want [%q]", hits, tt.want)
+			}
+		})
+	}
+	if got := NewClient().endpointFor("openrouter", &Request{}); got != "" {
+		t.Errorf("endpointFor without overrides = %q, want empty", got)
+	}
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	type sent struct {
+		url, auth, referer string
+		payload            map[string]interface{}
+	}
+	var last sent
+	c := NewClient(WithHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		last = sent{req.URL.String(), req.Header.Get("Authorization"), req.Header.Get("HTTP-Referer"), decodeBody(t, body)}
+		if stream, _ := last.payload["stream"].(bool); stream {
+			return cannedResponse(req, http.StatusOK, "text/event-stream",
+				"data: {\"model\":\"gpt-4o-2024-08-06\",\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n"+
+					"data: {\"choices\":[{\"delta\":{\"content\":\" there\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n"), nil
+		}
+		return cannedResponse(req, http.StatusOK, "application/json", `{
+			"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4o-2024-08-06",
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": "Hi there"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 9, "completion_tokens": 2, "total_tokens": 11}
+		}`), nil
+	})}))
+
+	req := &Request{Provider: "openai", Model: "gpt-4o", APIKey: "sk-test", SystemPrompt: "Be brief.", Prompt: "hi"}
+	resp, err := c.Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if resp.Content != "Hi there" || resp.Model != "gpt-4o-2024-08-06" || resp.FinishReason != "stop" {
+		t.Errorf("response = %q, model %q, finish %q", resp.Content, resp.Model, resp.FinishReason)
+	}
+	if want := (TokenUsage{PromptTokens: 9, CompletionTokens: 2, TotalTokens: 11}); resp.Usage == nil || *resp.Usage != want {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+	if last.url != defaultOpenAIURL || last.payload["stream"] != false {
+		t.Errorf("Send posted to %q with stream %v", last.url, last.payload["stream"])
+	}
+
+	_, stream, err := collectStream(t, c, req)
+	if err != nil {
+		t.Fatalf("SendStream: %v", err)
+	}
+	if stream.Content != "Hi there" || stream.Model != "gpt-4o-2024-08-06" {
+		t.Errorf("stream = %q, model %q", stream.Content, stream.Model)
+	}
+
+	if last.url != defaultOpenAIURL {
+		t.Errorf("url = %q, want %q", last.url, defaultOpenAIURL)
+	}
+	if last.auth != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", last.auth)
+	}
+	if last.referer != "" {
+		t.Errorf("OpenRouter attribution header sent to OpenAI: %q", last.referer)
+	}
+	if last.payload["model"] != "gpt-4o" || last.payload["stream"] != true {
+		t.Errorf("payload = %v", last.payload)
+	}
+	if got := roles(sentMessages(last.payload)); !reflect.DeepEqual(got, []string{"system:Be brief.", "user:hi"}) {
+		t.Errorf("messages = %q", got)
+	}
+}
+
+func TestGroqProvider(t *testing.T) {
+	var (
+		path, auth string
+		payload    map[string]interface{}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		path, auth, payload = r.URL.Path, r.Header.Get("Authorization"), decodeBody(t, body)
+		if payload["model"] == "decommissioned" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"message":"The model has been decommissioned","type":"invalid_request_error","code":"model_decommissioned"}}`)
+			return
+		}
+		if stream, _ := payload["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"model\":\"llama-3.1-8b-instant\",\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n"+
+				"data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n"+
+				"data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}],\"x_groq\":{\"usage\":{\"prompt_tokens\":8,\"completion_tokens\":1,\"total_tokens\":9}}}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","model":"llama-3.1-8b-instant",
+			"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":8,"completion_tokens":1,"total_tokens":9,"queue_time":0.01}}`)
+	}))
+	defer srv.Close()
+	c := NewClient(WithHTTPClient(rewriteClient(srv)))
+
+	req := &Request{Provider: "groq", Model: "llama-3.1-8b-instant", APIKey: "gsk-test", Prompt: "hi"}
+	resp, err := c.Send(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if resp.Content != "Hi" || resp.Model != "llama-3.1-8b-instant" || resp.Usage == nil || resp.Usage.TotalTokens != 9 {
+		t.Errorf("response = %q, model %q, usage %+v", resp.Content, resp.Model, resp.Usage)
+	}
+	if path != "/openai/v1/chat/completions" || auth != "Bearer gsk-test" {
+		t.Errorf("posted to %q with Authorization %q", path, auth)
+	}
+	if payload["model"] != "llama-3.1-8b-instant" || payload["stream"] != false {
+		t.Errorf("payload = %v", payload)
+	}
+
+	_, stream, err := collectStream(t, c, req)
+	if err != nil {
+		t.Fatalf("SendStream: %v", err)
+	}
+	if stream.Content != "Hi" || stream.Model != "llama-3.1-8b-instant" || payload["stream"] != true {
+		t.Errorf("stream = %q, model %q, payload %v", stream.Content, stream.Model, payload)
+	}
+
+	_, err = c.Send(context.Background(), &Request{Provider: "groq", Model: "decommissioned", APIKey: "gsk-test", Prompt: "hi"})
+	if apiErr, ok := AsAPIError(err); !ok || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("err = %v, want the 400 APIError", err)
+	}
+}
@@ -0,0 +1,177 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	statusCodes map[int]bool
+	onRetry     func(attempt int, err error, nextDelay time.Duration)
+}
+
+// 529 is Anthropic's "overloaded" status.
+var defaultRetryableStatusCodes = []int{429, 500, 502, 503, 504, 529}
+
+func DefaultRetryableStatusCodes() []int {
+	return append([]int(nil), defaultRetryableStatusCodes...)
+}
+
+func WithRetry(maxAttempts int, baseDelay time.Duration) ClientOption {
+	return func(c *Client) {
+		if maxAttempts < 1 {
+			maxAttempts = 1
+		}
+		c.retry = &retryPolicy{maxAttempts: maxAttempts, baseDelay: baseDelay}
+	}
+}
+
+func WithRetryableStatusCodes(codes ...int) ClientOption {
+	return func(c *Client) { c.retryStatusCodes = codes }
+}
+
+// WithOnRetry registers a callback invoked before each backoff sleep when
+// WithRetry is enabled. attempt is the number of the attempt that failed;
+// err is the transport error or an *APIError for a retryable status.
+func WithOnRetry(fn func(attempt int, err error, nextDelay time.Duration)) ClientOption {
+	return func(c *Client) { c.onRetry = fn }
+}
+
+func newStatusCodeSet(codes []int) map[int]bool {
+	set := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
+
+type retryTransport struct {
+	base   http.RoundTripper
+	policy *retryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isReplayable(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	attemptReq := req
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(attemptReq)
+		if attempt >= t.policy.maxAttempts || !t.policy.shouldRetry(resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay, ok := retryAfter(resp)
+		if !ok {
+			delay = t.policy.backoff(attempt)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+		if resp != nil {
+			if t.policy.onRetry != nil {
+				body, _ := io.ReadAll(resp.Body)
+				err = &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+			} else {
+				_, _ = io.Copy(io.Discard, resp.Body)
+			}
+			resp.Body.Close()
+		}
+		if t.policy.onRetry != nil {
+			t.policy.onRetry(attempt, err, delay)
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		// POST bodies are consumed by the first attempt; rewind them.
+		if req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+	}
+}
+
+// maxRetryDelay caps the exponential backoff; Retry-After is not capped.
+const maxRetryDelay = 30 * time.Second
+
+// backoff doubles the base delay per attempt, up to maxRetryDelay, and picks
+// a random point in the upper half of it, so clients failing together do not
+// retry in lockstep.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	d := p.baseDelay
+	for i := 1; i < attempt && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// retryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		d := time.Until(at)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// isReplayable reports whether req can be sent again: bodiless requests and
+// those whose body can be recreated through GetBody, as http.NewRequest sets
+// up for the in-memory JSON and multipart payloads used here.
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func (p *retryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return p.statusCodes[resp.StatusCode]
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
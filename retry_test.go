@@ -0,0 +1,214 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests to every path with status
+// and then answers with body.
+func flakyServer(t *testing.T, failures, status int, body string) (*httptest.Server, func(path string) int) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		calls = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		mu.Unlock()
+		if n <= failures {
+			w.WriteHeader(status)
+			io.WriteString(w, "try again")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls[path]
+	}
+}
+
+func TestRetryAccountAPIs(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+		call func(c *Client) error
+	}{
+		{"balance", "/account/balance", `{"balance":5}`, func(c *Client) error {
+			_, err := c.GetBalance(context.Background(), &BalanceRequest{Provider: "pollinations"})
+			return err
+		}},
+		{"profile", "/account/profile", `{"email":"a@b.c"}`, func(c *Client) error {
+			_, err := c.GetProfile(context.Background(), &ProfileRequest{Provider: "pollinations"})
+			return err
+		}},
+		{"models", "/text/models", `[{"name":"openai"}]`, func(c *Client) error {
+			_, err := c.ListTextModels(context.Background(), &ModelsRequest{Provider: "pollinations"})
+			return err
+		}},
+		{"usage", "/account/usage", `{"records":[]}`, func(c *Client) error {
+			_, err := c.GetUsage(context.Background(), &UsageRequest{Provider: "pollinations"})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, _ := flakyServer(t, 1, http.StatusServiceUnavailable, tt.body)
+			if _, ok := AsAPIError(tt.call(NewClient(WithHTTPClient(rewriteClient(plain))))); !ok {
+				t.Fatal("without retry: want the 503 APIError")
+			}
+
+			srv, calls := flakyServer(t, 1, http.StatusServiceUnavailable, tt.body)
+			if err := tt.call(NewClient(WithHTTPClient(rewriteClient(srv)), WithRetry(3, time.Millisecond))); err != nil {
+				t.Fatalf("with retry: %v", err)
+			}
+			if got := calls(tt.path); got != 2 {
+				t.Errorf("calls = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestRetryableStatusCodes(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		opts      []ClientOption
+		wantCalls int
+	}{
+		{"529 retried by default", 529, nil, 2},
+		{"420 not retried by default", 420, nil, 1},
+		{"420 added", 420, []ClientOption{WithRetryableStatusCodes(420)}, 2},
+		{"custom set replaces the defaults", 529, []ClientOption{WithRetryableStatusCodes(420)}, 1},
+		{"400 never retried", 400, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := flakyServer(t, 1, tt.status, `{"model":"claude","content":[{"type":"text","text":"ok"}]}`)
+			opts := append([]ClientOption{WithRetry(3, time.Millisecond)}, tt.opts...)
+			_, err := NewClient(opts...).Send(context.Background(), &Request{Provider: "anthropic", Endpoint: srv.URL + "/v1/messages", Model: "claude", Prompt: "hi"})
+			if got := calls("/v1/messages"); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+			if tt.wantCalls == 2 && err != nil {
+				t.Errorf("Send: %v", err)
+			}
+			if apiErr, ok := AsAPIError(err); tt.wantCalls == 1 && (!ok || apiErr.StatusCode != tt.status) {
+				t.Errorf("err = %v, want the %d APIError", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestRetryRewindsPostBody(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		name := "send"
+		if stream {
+			name = "stream"
+		}
+		t.Run(name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				bodies []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				bodies = append(bodies, string(body))
+				n := len(bodies)
+				mu.Unlock()
+				if n <= 2 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				if stream {
+					io.WriteString(w, "data: "+deltaEvent("ok")+"\n\ndata: [DONE]\n\n")
+					return
+				}
+				io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}]}`)
+			}))
+			defer srv.Close()
+
+			c := NewClient(WithRetry(3, time.Millisecond))
+			req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}
+			var err error
+			if stream {
+				_, _, err = collectStream(t, c, req)
+			} else {
+				_, err = c.Send(context.Background(), req)
+			}
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if len(bodies) != 3 {
+				t.Fatalf("server saw %d requests, want 3", len(bodies))
+			}
+			for i, body := range bodies {
+				if body == "" || body != bodies[0] {
+					t.Errorf("attempt %d body = %q, want %q", i+1, body, bodies[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryBackoffCap(t *testing.T) {
+	p := &retryPolicy{baseDelay: time.Second}
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{1, time.Second},
+		{3, 4 * time.Second},
+		{5, 16 * time.Second},
+		{6, maxRetryDelay},
+		{64, maxRetryDelay},
+		{1000, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			d := p.backoff(tt.attempt)
+			if d < tt.ceiling/2 || d > tt.ceiling {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", tt.attempt, d, tt.ceiling/2, tt.ceiling)
+			}
+		}
+	}
+}
+
+func TestOnRetry(t *testing.T) {
+	srv, calls := flakyServer(t, 3, http.StatusServiceUnavailable, `{"balance":5}`)
+	var attempts []int
+	onRetry := func(attempt int, err error, nextDelay time.Duration) {
+		apiErr, ok := AsAPIError(err)
+		if !ok || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Body != "try again" {
+			t.Errorf("attempt %d: err = %v, want the 503 APIError", attempt, err)
+		}
+		if nextDelay <= 0 {
+			t.Errorf("attempt %d: nextDelay = %v", attempt, nextDelay)
+		}
+		attempts = append(attempts, attempt)
+	}
+	c := NewClient(WithHTTPClient(rewriteClient(srv)), WithRetry(4, time.Millisecond), WithOnRetry(onRetry))
+	if _, err := c.GetBalance(context.Background(), &BalanceRequest{Provider: "pollinations"}); err != nil {
+		t.Fatalf("GetBalance: %v", err)
+	}
+	if got := calls("/account/balance"); got != 4 {
+		t.Errorf("calls = %d, want 4", got)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(attempts, want) {
+		t.Errorf("attempts = %v, want %v", attempts, want)
+	}
+}
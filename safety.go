@@ -0,0 +1,65 @@
+package llmclient
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// SafetyAnnotation is one category of a provider's content filter verdict,
+// as reported by Azure OpenAI in content_filter_results and
+// prompt_filter_results.
+type SafetyAnnotation struct {
+	Source   string // "prompt" or "completion"
+	Category string
+	Filtered bool
+	Severity string
+	Detected bool
+}
+
+type contentFilterResults map[string]struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity"`
+	Detected bool   `json:"detected"`
+}
+
+func parseSafetyAnnotations(body []byte) []SafetyAnnotation {
+	var r struct {
+		PromptFilterResults []struct {
+			ContentFilterResults contentFilterResults `json:"content_filter_results"`
+		} `json:"prompt_filter_results"`
+		Choices []struct {
+			ContentFilterResults contentFilterResults `json:"content_filter_results"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil
+	}
+
+	var annotations []SafetyAnnotation
+	for _, p := range r.PromptFilterResults {
+		annotations = appendSafetyAnnotations(annotations, "prompt", p.ContentFilterResults)
+	}
+	if len(r.Choices) > 0 {
+		annotations = appendSafetyAnnotations(annotations, "completion", r.Choices[0].ContentFilterResults)
+	}
+	return annotations
+}
+
+func appendSafetyAnnotations(annotations []SafetyAnnotation, source string, results contentFilterResults) []SafetyAnnotation {
+	categories := make([]string, 0, len(results))
+	for category := range results {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		result := results[category]
+		annotations = append(annotations, SafetyAnnotation{
+			Source:   source,
+			Category: category,
+			Filtered: result.Filtered,
+			Severity: result.Severity,
+			Detected: result.Detected,
+		})
+	}
+	return annotations
+}
@@ -0,0 +1,62 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const azureFilteredResponse = `{
+	"id": "chatcmpl-9",
+	"object": "chat.completion",
+	"model": "gpt-4o-2024-05-13",
+	"prompt_filter_results": [{
+		"prompt_index": 0,
+		"content_filter_results": {
+			"hate": {"filtered": false, "severity": "safe"},
+			"jailbreak": {"filtered": false, "detected": false},
+			"violence": {"filtered": false, "severity": "low"}
+		}
+	}],
+	"choices": [{
+		"index": 0,
+		"finish_reason": "content_filter",
+		"message": {"role": "assistant", "content": null},
+		"content_filter_results": {
+			"protected_material_text": {"filtered": false, "detected": true},
+			"violence": {"filtered": true, "severity": "medium"}
+		}
+	}]
+}`
+
+func TestSafetyAnnotations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, azureFilteredResponse)
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient().Send(context.Background(), &Request{Provider: srv.URL + "/openai/deployments/gpt-4o/chat/completions", Model: "gpt-4o", Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if resp.FinishReason != "content_filter" || resp.Content != "" {
+		t.Errorf("finish reason = %q, content = %q", resp.FinishReason, resp.Content)
+	}
+	want := []SafetyAnnotation{
+		{Source: "prompt", Category: "hate", Severity: "safe"},
+		{Source: "prompt", Category: "jailbreak"},
+		{Source: "prompt", Category: "violence", Severity: "low"},
+		{Source: "completion", Category: "protected_material_text", Detected: true},
+		{Source: "completion", Category: "violence", Filtered: true, Severity: "medium"},
+	}
+	if !reflect.DeepEqual(resp.SafetyAnnotations, want) {
+		t.Errorf("annotations = %+v\nwant %+v", resp.SafetyAnnotations, want)
+	}
+
+	if got := parseSafetyAnnotations([]byte(`{"choices":[{"message":{"content":"ok"}}]}`)); got != nil {
+		t.Errorf("annotations without filter results = %+v", got)
+	}
+}
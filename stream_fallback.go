@@ -0,0 +1,44 @@
+package llmclient
+
+import (
+	"context"
+	"net/http"
+)
+
+// WithFallbackToNonStreamOnError repeats a stream that the provider refuses
+// before sending anything (a 4xx such as 400 for "stream": true) as a normal
+// request and delivers the whole answer as one chunk followed by Done.
+func WithFallbackToNonStreamOnError() ClientOption {
+	return func(c *Client) { c.streamFallback = true }
+}
+
+// isStreamRejected reports a client error that may come from the stream flag
+// itself. Auth failures and rate limits would fail the same way without it.
+func isStreamRejected(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	if !ok || apiErr.StatusCode < 400 || apiErr.StatusCode >= 500 {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return true
+}
+
+func (c *Client) sendAsStream(ctx context.Context, req *Request, history []Message, emit StreamCallback) error {
+	provider, err := c.newProvider(req)
+	if err != nil {
+		return err
+	}
+	resp, err := provider.Send(ctx, history, req.Images, req.SystemPrompt)
+	if err != nil {
+		return err
+	}
+	if resp.Content != "" {
+		if err := emit(StreamChunk{Content: resp.Content, Model: resp.Model}); err != nil {
+			return err
+		}
+	}
+	return emit(StreamChunk{Model: resp.Model, Done: true, Usage: resp.Usage})
+}
@@ -0,0 +1,68 @@
+package llmclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFallbackToNonStreamOnError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		fallback   bool
+		wantCalls  []bool // the stream flag of each request
+		wantStatus int    // 0: the fallback answer is delivered
+	}{
+		{"400 falls back", http.StatusBadRequest, true, []bool{true, false}, 0},
+		{"422 falls back", http.StatusUnprocessableEntity, true, []bool{true, false}, 0},
+		{"401 is returned", http.StatusUnauthorized, true, []bool{true}, http.StatusUnauthorized},
+		{"500 is returned", http.StatusInternalServerError, true, []bool{true}, http.StatusInternalServerError},
+		{"off by default", http.StatusBadRequest, false, []bool{true}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []bool
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				stream, _ := decodeBody(t, body)["stream"].(bool)
+				calls = append(calls, stream)
+				if stream {
+					w.WriteHeader(tt.status)
+					io.WriteString(w, `{"error":{"message":"streaming is not supported for this model"}}`)
+					return
+				}
+				io.WriteString(w, `{"model":"m-1","choices":[{"message":{"content":"whole answer"}}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`)
+			}))
+			defer srv.Close()
+
+			var opts []ClientOption
+			if tt.fallback {
+				opts = append(opts, WithFallbackToNonStreamOnError())
+			}
+			chunks, resp, err := collectStream(t, NewClient(opts...), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("requests (stream flag) = %v, want %v", calls, tt.wantCalls)
+			}
+			if tt.wantStatus != 0 {
+				if apiErr, ok := AsAPIError(err); !ok || apiErr.StatusCode != tt.wantStatus {
+					t.Errorf("err = %v, want the %d APIError", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendStream: %v", err)
+			}
+			usage := &TokenUsage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}
+			want := []StreamChunk{{Content: "whole answer", Model: "m-1"}, {Model: "m-1", Done: true, Usage: usage}}
+			if !reflect.DeepEqual(chunks, want) {
+				t.Errorf("chunks = %+v, want %+v", chunks, want)
+			}
+			if resp.Content != "whole answer" || !reflect.DeepEqual(resp.Usage, usage) {
+				t.Errorf("response = %q, usage %+v", resp.Content, resp.Usage)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrStreamTimeout = errors.New("stream timeout")
+
+// WithStreamIdleTimeout aborts a stream when no chunk arrives for d. Unless
+// WithStreamFirstTokenTimeout is set it also bounds the wait for the first
+// token.
+func WithStreamIdleTimeout(d time.Duration) ClientOption {
+	return func(c *Client) { c.streamIdleTimeout = d }
+}
+
+// WithStreamFirstTokenTimeout bounds the wait for the first content chunk
+// separately, so a cold model load can take longer than the gap allowed
+// between later chunks.
+func WithStreamFirstTokenTimeout(d time.Duration) ClientOption {
+	return func(c *Client) { c.streamFirstTokenTimeout = d }
+}
+
+// streamWatchdog cancels the stream context when the current deadline
+// passes: first the first-token budget, then the idle timeout, re-armed on
+// every chunk.
+type streamWatchdog struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	idle    time.Duration
+	started bool
+	cancel  context.CancelCauseFunc
+}
+
+func (c *Client) startStreamWatchdog(ctx context.Context) (context.Context, *streamWatchdog) {
+	first := c.streamFirstTokenTimeout
+	if first <= 0 {
+		first = c.streamIdleTimeout
+	}
+	if first <= 0 && c.streamIdleTimeout <= 0 {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &streamWatchdog{idle: c.streamIdleTimeout, cancel: cancel}
+	if first > 0 {
+		w.timer = time.AfterFunc(first, func() {
+			cancel(fmt.Errorf("%w: no first token within %s", ErrStreamTimeout, first))
+		})
+	}
+	return ctx, w
+}
+
+func (w *streamWatchdog) observe(chunk StreamChunk) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.started {
+		if chunk.Content == "" && chunk.Reasoning == "" {
+			return
+		}
+		w.started = true
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if w.idle > 0 {
+		idle := w.idle
+		w.timer = time.AfterFunc(idle, func() {
+			w.cancel(fmt.Errorf("%w: no chunk for %s", ErrStreamTimeout, idle))
+		})
+	}
+}
+
+// stop disarms the watchdog and returns its timeout error, if it fired.
+func (w *streamWatchdog) stop(ctx context.Context) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	if cause := context.Cause(ctx); errors.Is(cause, ErrStreamTimeout) {
+		return cause
+	}
+	w.cancel(nil)
+	return nil
+}
@@ -0,0 +1,64 @@
+package llmclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowStreamServer sends two content chunks, the first after firstDelay and
+// the second gap later.
+func slowStreamServer(t *testing.T, firstDelay, gap time.Duration) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		for _, d := range []time.Duration{firstDelay, gap} {
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done():
+				return
+			}
+			io.WriteString(w, "data: "+deltaEvent("tok ")+"\n\n")
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamTimeouts(t *testing.T) {
+	tests := []struct {
+		name             string
+		firstDelay, gap  time.Duration
+		firstToken, idle time.Duration
+		wantTimeout      bool
+	}{
+		{"slow first token within the budget", 150 * time.Millisecond, 0, time.Second, 50 * time.Millisecond, false},
+		{"first token over the budget", 500 * time.Millisecond, 0, 50 * time.Millisecond, time.Second, true},
+		{"idle timeout bounds the first token", 500 * time.Millisecond, 0, 0, 50 * time.Millisecond, true},
+		{"gap after the first token", 0, 500 * time.Millisecond, time.Second, 50 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := slowStreamServer(t, tt.firstDelay, tt.gap)
+			c := NewClient(WithStreamFirstTokenTimeout(tt.firstToken), WithStreamIdleTimeout(tt.idle))
+			_, resp, err := collectStream(t, c, &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+			if tt.wantTimeout {
+				if !errors.Is(err, ErrStreamTimeout) {
+					t.Errorf("err = %v, want ErrStreamTimeout", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendStream: %v", err)
+			}
+			if resp.Content != "tok tok " {
+				t.Errorf("content = %q", resp.Content)
+			}
+		})
+	}
+}
@@ -10,17 +10,31 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
+// StreamChunk carries a piece of the answer in Content and, for reasoning
+// models, a piece of the chain-of-thought in Reasoning. Role is set on the
+// chunk that starts a new message (OpenAI's delta.role), not on the repeats
+// some providers send with every delta.
 type StreamChunk struct {
-	Content string
-	Done    bool
+	Content   string
+	Reasoning string
+	Role      string
+	Model     string
+	Done      bool
+	Usage     *TokenUsage
 }
 
 type StreamCallback func(chunk StreamChunk) error
 
 type StreamResponse struct {
 	Content string
+	Model   string
+	Usage   *TokenUsage
+	Headers map[string]string
 }
 
 func (c *Client) SendStream(ctx context.Context, req *Request, callback StreamCallback) (*StreamResponse, error) {
@@ -30,104 +44,277 @@ func (c *Client) SendStream(ctx context.Context, req *Request, callback StreamCa
 	if callback == nil {
 		return nil, errors.New("callback is nil")
 	}
-
-	provider, err := c.newStreamProvider(req)
+	if err := c.budget.check(); err != nil {
+		return nil, err
+	}
+	req = c.applyDefaults(req)
+	if err := validateRequestMessages(req); err != nil {
+		return nil, err
+	}
+	model := c.lookupModel(ctx, req)
+	ctx, captured := c.startHeaderCapture(ctx)
+	ctx = c.withStreamLineSize(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
 	if err != nil {
 		return nil, err
 	}
+	defer release()
+	adaptToModel(req, model)
+	if c.budget != nil {
+		req.StreamUsage = true
+	}
+	history := c.buildHistory(req)
+	if c.rejectsSystemPrompt(req.Model) {
+		history = foldSystemPrompt(req.SystemPrompt, history)
+		req.SystemPrompt = ""
+	}
+	if err := c.checkFits(req.Model, req.SystemPrompt, history); err != nil {
+		return nil, err
+	}
 
-	history := req.Messages
-	if len(history) == 0 && req.Prompt != "" {
-		history = []Message{{Role: "user", Content: req.Prompt}}
+	var buffer *streamBuffer
+	if req.StreamBuffer > 0 {
+		buffer = newStreamBuffer(req.StreamBuffer, callback)
+		callback = buffer.push
 	}
 
-	var fullContent strings.Builder
-	err = provider.SendStream(ctx, history, req.Images, req.SystemPrompt, func(chunk StreamChunk) error {
-		if !chunk.Done {
-			fullContent.WriteString(chunk.Content)
-		}
+	ctx, watchdog := c.startStreamWatchdog(ctx)
+	ctx, received := c.startByteCount(ctx)
+
+	var acc StreamAccumulator
+	delivered := false
+	emit := func(chunk StreamChunk) error {
+		watchdog.observe(chunk)
+		delivered = true
+		acc.Add(chunk)
 		return callback(chunk)
-	})
-	if err != nil {
-		return nil, err
 	}
 
-	return &StreamResponse{Content: fullContent.String()}, nil
-}
+	var stop *stopFilter
+	if req.ClientSideStop && len(req.Stop) > 0 {
+		stop = newStopFilter(req.Stop, emit)
+		emit = stop.handle
+	}
 
-func (c *Client) newStreamProvider(req *Request) (streamingProvider, error) {
-	name := strings.ToLower(strings.TrimSpace(req.Provider))
+	var streamedModel string
+	stream := func(model string) error {
+		streamedModel = model
+		attempt := *req
+		attempt.Model = model
+
+		keys := c.requestKeys(req)
+		for i := 0; ; i++ {
+			attempt.APIKey = keys[i]
+			provider, err := c.newStreamProvider(&attempt)
+			if err != nil {
+				return err
+			}
+			received.reset()
+			err = provider.SendStream(ctx, history, req.Images, req.SystemPrompt, emit)
+			if err != nil && isKeyRejected(err) && !delivered && i < len(keys)-1 && ctx.Err() == nil {
+				continue
+			}
+			if c.streamFallback && !delivered && isStreamRejected(err) && ctx.Err() == nil {
+				err = c.sendAsStream(ctx, &attempt, history, emit)
+			}
+			return withProvider(err, req.Provider)
+		}
+	}
 
-	switch name {
-	case "ollama":
-		endpoint := req.Endpoint
-		if endpoint == "" {
-			endpoint = defaultOllamaURL
+	for _, candidate := range requestModels(req) {
+		err = stream(candidate)
+		if err != nil && !delivered {
+			if replacement, ok := c.deprecationReplacement(candidate, err); ok {
+				err = stream(replacement)
+			}
 		}
-		return &ollamaProvider{model: req.Model, endpoint: endpoint, client: c.httpClient}, nil
-	case "pollinations":
-		return &pollinationsProvider{model: req.Model, key: req.APIKey, client: c.httpClient, seed: req.Seed}, nil
-	case "openrouter":
-		return &openRouterProvider{model: req.Model, key: req.APIKey, client: c.httpClient}, nil
-	default:
-		if isURL(name) {
-			return &genericProvider{endpoint: name, model: req.Model, key: req.APIKey, client: c.httpClient}, nil
+		if err == nil || delivered || !isFailoverError(err) || ctx.Err() != nil {
+			break
 		}
-		if isURL(req.Endpoint) {
-			return &genericProvider{endpoint: req.Endpoint, model: req.Model, key: req.APIKey, client: c.httpClient}, nil
+	}
+	if timeoutErr := watchdog.stop(ctx); timeoutErr != nil && err != nil {
+		err = timeoutErr
+	}
+	if stop != nil && err == nil {
+		err = stop.flush()
+	}
+	if buffer != nil {
+		if bufErr := buffer.close(); bufErr != nil && (err == nil || errors.Is(err, errStopSequence)) {
+			err = bufErr
 		}
-		return nil, fmt.Errorf("unknown provider: %s", req.Provider)
 	}
+	if err != nil && !errors.Is(err, errStopSequence) {
+		return nil, err
+	}
+	result := acc.Result()
+	if c.errorOnEmptyContent && result.Content == "" {
+		return nil, ErrEmptyContent
+	}
+
+	c.budget.record(streamedModel, c.billedUsage(result.Usage, req.SystemPrompt, history, result.Content))
+	c.usage.record(result.Usage)
+	c.observeResponseBytes(req.Provider, received.count())
+	return &StreamResponse{Content: result.Content, Model: result.Model, Usage: result.Usage, Headers: captured.headers()}, nil
 }
 
-type streamingProvider interface {
-	SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error
+// StreamChannel runs SendStream in a goroutine and delivers the chunks on a
+// channel that is closed when the stream ends or ctx is cancelled. A failure
+// is sent on the error channel, which is closed after the chunk channel.
+// Callers must drain the chunk channel (or cancel ctx), otherwise the
+// goroutine blocks forever on the next send.
+func (c *Client) StreamChannel(ctx context.Context, req *Request) (<-chan StreamChunk, <-chan error) {
+	chunks := make(chan StreamChunk)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(chunks)
+		_, err := c.SendStream(ctx, req, func(chunk StreamChunk) error {
+			select {
+			case chunks <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return chunks, errs
 }
 
-func (p *ollamaProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
-	msgs := messagesToMaps(history, images, systemPrompt)
-	payload := map[string]interface{}{"model": p.model, "messages": msgs, "stream": true}
-	return postJSONStream(ctx, p.client, p.endpoint, payload, "", callback)
+// StartStream runs SendStream in the background. Calling cancel stops the
+// generation; done receives the result error (nil on success, or
+// context.Canceled after cancel) and is then closed.
+func (c *Client) StartStream(ctx context.Context, req *Request, callback StreamCallback) (cancel func(), done <-chan error) {
+	ctx, cancelCtx := context.WithCancel(ctx)
+	result := make(chan error, 1)
+	go func() {
+		defer close(result)
+		defer cancelCtx()
+		_, err := c.SendStream(ctx, req, callback)
+		result <- err
+	}()
+	return cancelCtx, result
 }
 
-func (p *pollinationsProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
-	msgs := messagesToMaps(history, images, systemPrompt)
-	payload := map[string]interface{}{"model": p.model, "messages": msgs, "stream": true}
-	if p.seed != nil {
-		payload["seed"] = *p.seed
+// StreamAccumulator collects streamed chunks into the complete reply. It is
+// safe for concurrent use, so one accumulator can be fed from a callback and
+// read from another goroutine.
+type StreamAccumulator struct {
+	mu      sync.Mutex
+	content strings.Builder
+	model   string
+	usage   *TokenUsage
+}
+
+func (a *StreamAccumulator) Add(chunk StreamChunk) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.model == "" {
+		a.model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		a.usage = chunk.Usage
+	}
+	if !chunk.Done {
+		a.content.WriteString(chunk.Content)
+	}
+}
+
+func (a *StreamAccumulator) String() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.content.String()
+}
+
+func (a *StreamAccumulator) Result() *Response {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &Response{Content: a.content.String(), Model: a.model, Usage: a.usage}
+}
+
+func (c *Client) newStreamProvider(req *Request) (StreamingChatProvider, error) {
+	p, err := c.newProvider(req)
+	if err != nil {
+		return nil, err
 	}
+	sp, ok := p.(StreamingChatProvider)
+	if !ok {
+		return nil, fmt.Errorf("provider does not support streaming: %s", req.Provider)
+	}
+	return sp, nil
+}
 
-	endpoint := pollinationsPaidURL
-	if p.key == "" {
-		endpoint = pollinationsFreeURL
+func (c *Client) RawChatStream(ctx context.Context, url string, payload map[string]any, key string, callback StreamCallback) error {
+	if !isURL(url) {
+		return fmt.Errorf("invalid url: %s", url)
+	}
+	if callback == nil {
+		return errors.New("callback is nil")
 	}
+	streamPayload := make(map[string]any, len(payload)+1)
+	for k, v := range payload {
+		streamPayload[k] = v
+	}
+	if _, ok := streamPayload["stream"]; !ok {
+		streamPayload["stream"] = true
+	}
+	return postJSONStream(ctx, c.httpClient, url, streamPayload, key, nil, callback)
+}
+
+func (p *ollamaProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
+	if p.native {
+		body, err := openJSONStream(ctx, p.client, p.endpoint, p.nativePayload(history, images, systemPrompt, true), p.key, p.header)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		return parseOllamaStream(ctx, body, callback)
+	}
+	return postJSONStream(ctx, p.client, p.endpoint, p.newPayload(p.model, history, images, systemPrompt, true), p.key, p.header, callback)
+}
 
-	return postJSONStream(ctx, p.client, endpoint, payload, p.key, callback)
+func (p *pollinationsProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
+	payload := p.newPayload(p.model, history, images, systemPrompt, true)
+	return postJSONStream(ctx, p.client, p.endpoint(), payload, p.key, p.header, callback)
 }
 
 func (p *openRouterProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
-	msgs := messagesToMaps(history, images, systemPrompt)
-	payload := map[string]interface{}{"model": p.model, "messages": msgs, "stream": true}
-	return postJSONStream(ctx, p.client, defaultOpenRouterURL, payload, p.key, callback)
+	payload := p.payload(history, images, systemPrompt, true)
+	return postJSONStream(ctx, p.client, p.endpoint, payload, p.key, p.header, callback)
+}
+
+func (p *openAIProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
+	payload := p.payload(history, images, systemPrompt, true)
+	return postJSONStream(ctx, p.client, p.endpoint, payload, p.key, p.header, callback)
 }
 
 func (p *genericProvider) SendStream(ctx context.Context, history []Message, images []string, systemPrompt string, callback StreamCallback) error {
-	msgs := messagesToMaps(history, images, systemPrompt)
-	payload := map[string]interface{}{"model": p.model, "messages": msgs, "stream": true}
-	return postJSONStream(ctx, p.client, p.endpoint, payload, p.key, callback)
+	return postJSONStream(ctx, p.client, p.endpoint, p.payload(history, images, systemPrompt, true), p.key, p.header, callback)
+}
+
+func postJSONStream(ctx context.Context, client *http.Client, url string, payload interface{}, key string, header http.Header, callback StreamCallback) error {
+	body, err := openJSONStream(ctx, client, url, payload, key, header)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return parseSSEStream(ctx, body, callback)
 }
 
-func postJSONStream(ctx context.Context, client *http.Client, url string, payload interface{}, key string, callback StreamCallback) error {
+func openJSONStream(ctx context.Context, client *http.Client, url string, payload interface{}, key string, header http.Header) (io.ReadCloser, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("marshal: %w", err)
+		return nil, fmt.Errorf("marshal: %w", err)
 	}
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+		return nil, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "text/event-stream")
+	setHeaders(req, header)
 	if key != "" {
 		req.Header.Set("Authorization", "Bearer "+key)
 	}
@@ -138,43 +325,97 @@ func postJSONStream(ctx context.Context, client *http.Client, url string, payloa
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request: %w", err)
+		return nil, fmt.Errorf("request: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
 		respBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("api error %d: %s", resp.StatusCode, string(respBytes))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(respBytes)}
 	}
+	if err := checkContentType(resp, nil); err != nil {
+		defer resp.Body.Close()
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, errorSnippetLen))
+		return nil, checkContentType(resp, snippet)
+	}
+
+	return resp.Body, nil
+}
 
-	return parseSSEStream(resp.Body, callback)
+// defaultMaxStreamLineSize bounds a single stream line. bufio.Scanner
+// defaults to 64KB, which a data: line carrying a large tool-call or
+// reasoning delta can exceed.
+const defaultMaxStreamLineSize = 8 << 20
+
+// WithMaxStreamLineSize changes the longest stream line (an SSE data: line
+// or an NDJSON object) that SendStream and TranscribeAudioStream accept,
+// 8MB by default. A longer line fails the stream with bufio.ErrTooLong.
+func WithMaxStreamLineSize(n int) ClientOption {
+	return func(c *Client) { c.maxStreamLineSize = n }
 }
 
-func parseSSEStream(reader io.Reader, callback StreamCallback) error {
+type streamLineSizeKey struct{}
+
+func (c *Client) withStreamLineSize(ctx context.Context) context.Context {
+	if c.maxStreamLineSize <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, streamLineSizeKey{}, c.maxStreamLineSize)
+}
+
+func newStreamScanner(ctx context.Context, reader io.Reader) *bufio.Scanner {
+	max := defaultMaxStreamLineSize
+	if n, ok := ctx.Value(streamLineSizeKey{}).(int); ok {
+		max = n
+	}
+	initial := 64 * 1024
+	if max < initial {
+		initial = max
+	}
 	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, initial), max)
+	return scanner
+}
+
+// parseSSEStream delivers the usage chunk that stream_options.include_usage
+// adds before [DONE] (it has an empty choices array) on the Done chunk.
+func parseSSEStream(ctx context.Context, reader io.Reader, callback StreamCallback) error {
+	var usage *TokenUsage
+	// Some providers repeat delta.role on every chunk; only the first one of
+	// a message, or a change of role, marks a message start.
+	var role string
+	scanner := newStreamScanner(ctx, reader)
 	for scanner.Scan() {
 		line := scanner.Text()
 		line = strings.TrimSpace(line)
 
-		if line == "" || !strings.HasPrefix(line, "data: ") {
+		if line == "" || !strings.HasPrefix(line, "data:") {
 			continue
 		}
 
-		data := strings.TrimPrefix(line, "data: ")
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
 		if data == "[DONE]" {
-			if err := callback(StreamChunk{Done: true}); err != nil {
+			if err := callback(StreamChunk{Done: true, Usage: usage}); err != nil {
 				return err
 			}
 			break
 		}
 
-		content, err := extractStreamContent(data)
+		chunk, err := extractStreamContent(data)
 		if err != nil {
 			continue
 		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if chunk.Role == role {
+			chunk.Role = ""
+		} else if chunk.Role != "" {
+			role = chunk.Role
+		}
 
-		if content != "" {
-			if err := callback(StreamChunk{Content: content}); err != nil {
+		if chunk.Content != "" || chunk.Reasoning != "" || chunk.Role != "" {
+			if err := callback(chunk); err != nil {
 				return err
 			}
 		}
@@ -183,24 +424,392 @@ func parseSSEStream(reader io.Reader, callback StreamCallback) error {
 	return scanner.Err()
 }
 
-func extractStreamContent(data string) (string, error) {
+func extractStreamContent(data string) (StreamChunk, error) {
 	type StreamResp struct {
+		Model   string `json:"model"`
 		Choices []struct {
 			Delta struct {
-				Content string `json:"content"`
+				Role             string `json:"role"`
+				Content          string `json:"content"`
+				ReasoningContent string `json:"reasoning_content"`
+				Reasoning        string `json:"reasoning"`
 			} `json:"delta"`
+			Text         string `json:"text"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
+		Usage *streamUsage `json:"usage"`
 	}
 
 	var r StreamResp
 	if err := json.Unmarshal([]byte(data), &r); err != nil {
-		return "", err
+		return StreamChunk{}, err
 	}
 
+	chunk := StreamChunk{Model: r.Model, Usage: r.Usage.tokenUsage()}
 	if len(r.Choices) > 0 {
-		return r.Choices[0].Delta.Content, nil
+		chunk.Role = r.Choices[0].Delta.Role
+		chunk.Content = r.Choices[0].Delta.Content
+		if chunk.Content == "" {
+			chunk.Content = r.Choices[0].Text
+		}
+		// DeepSeek uses reasoning_content, OpenRouter normalizes to reasoning.
+		chunk.Reasoning = r.Choices[0].Delta.ReasoningContent
+		if chunk.Reasoning == "" {
+			chunk.Reasoning = r.Choices[0].Delta.Reasoning
+		}
+	}
+
+	return chunk, nil
+}
+
+// streamUsage covers the terminal usage object of both Pollinations chat
+// endpoints: the paid one sends OpenAI's prompt/completion counts after an
+// empty choices array, while the free one may attach input/output counts to
+// the last content chunk, or send zeros or null when it does not count.
+type streamUsage struct {
+	PromptTokens     jsonInt `json:"prompt_tokens"`
+	CompletionTokens jsonInt `json:"completion_tokens"`
+	TotalTokens      jsonInt `json:"total_tokens"`
+	InputTokens      jsonInt `json:"input_tokens"`
+	OutputTokens     jsonInt `json:"output_tokens"`
+}
+
+func (u *streamUsage) tokenUsage() *TokenUsage {
+	if u == nil {
+		return nil
+	}
+	usage := &TokenUsage{PromptTokens: int64(u.PromptTokens), CompletionTokens: int64(u.CompletionTokens), TotalTokens: int64(u.TotalTokens)}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = int64(u.InputTokens)
+	}
+	if usage.CompletionTokens == 0 {
+		usage.CompletionTokens = int64(u.OutputTokens)
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	if usage.TotalTokens == 0 {
+		return nil
+	}
+	return usage
+}
+
+// forwardSideband passes a chunk's role marker and reasoning straight to next
+// and clears them, so wrappers that buffer or cut the answer text do not
+// delay or drop them.
+func forwardSideband(chunk *StreamChunk, next StreamCallback) error {
+	if chunk.Role == "" && chunk.Reasoning == "" {
+		return nil
+	}
+	sideband := StreamChunk{Role: chunk.Role, Reasoning: chunk.Reasoning, Model: chunk.Model}
+	chunk.Role, chunk.Reasoning = "", ""
+	return next(sideband)
+}
+
+var errStopSequence = errors.New("stop sequence reached")
+
+type stopFilter struct {
+	stops   []string
+	maxLen  int
+	pending string
+	model   string
+	next    StreamCallback
+}
+
+func newStopFilter(stops []string, next StreamCallback) *stopFilter {
+	f := &stopFilter{next: next}
+	for _, s := range stops {
+		if s == "" {
+			continue
+		}
+		f.stops = append(f.stops, s)
+		if len(s) > f.maxLen {
+			f.maxLen = len(s)
+		}
+	}
+	return f
+}
+
+func (f *stopFilter) handle(chunk StreamChunk) error {
+	if err := forwardSideband(&chunk, f.next); err != nil {
+		return err
+	}
+	if chunk.Done {
+		if err := f.flush(); err != nil {
+			return err
+		}
+		return f.next(chunk)
+	}
+
+	f.pending += chunk.Content
+	if chunk.Model != "" {
+		f.model = chunk.Model
+	}
+	cut := -1
+	for _, s := range f.stops {
+		if i := strings.Index(f.pending, s); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut >= 0 {
+		if cut > 0 {
+			if err := f.next(StreamChunk{Content: f.pending[:cut], Model: f.model}); err != nil {
+				return err
+			}
+		}
+		f.pending = ""
+		if err := f.next(StreamChunk{Model: f.model, Done: true}); err != nil {
+			return err
+		}
+		return errStopSequence
+	}
+
+	// Hold back a tail that could still be the beginning of a stop sequence.
+	keep := len(f.pending) - (f.maxLen - 1)
+	if keep <= 0 {
+		return nil
+	}
+	for keep > 0 && !utf8.RuneStart(f.pending[keep]) {
+		keep--
+	}
+	if keep == 0 {
+		return nil
+	}
+	out := f.pending[:keep]
+	f.pending = f.pending[keep:]
+	return f.next(StreamChunk{Content: out, Model: f.model})
+}
+
+func (f *stopFilter) flush() error {
+	if f.pending != "" {
+		out := f.pending
+		f.pending = ""
+		if err := f.next(StreamChunk{Content: out, Model: f.model}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamBuffer decouples network reads from a slow callback: chunks are read
+// ahead into a channel of bounded size and delivered in order by a separate
+// goroutine. When the channel is full, push blocks, so backpressure still
+// applies once the buffer is exhausted.
+type streamBuffer struct {
+	ch     chan StreamChunk
+	done   chan struct{}
+	failed chan struct{}
+	err    error
+	next   StreamCallback
+}
+
+func newStreamBuffer(size int, next StreamCallback) *streamBuffer {
+	b := &streamBuffer{
+		ch:     make(chan StreamChunk, size),
+		done:   make(chan struct{}),
+		failed: make(chan struct{}),
+		next:   next,
+	}
+	go b.run()
+	return b
+}
+
+func (b *streamBuffer) run() {
+	defer close(b.done)
+	for chunk := range b.ch {
+		if b.err != nil {
+			continue
+		}
+		if err := b.next(chunk); err != nil {
+			b.err = err
+			close(b.failed)
+		}
+	}
+}
+
+func (b *streamBuffer) push(chunk StreamChunk) error {
+	select {
+	case <-b.failed:
+		return b.err
+	default:
+	}
+	select {
+	case b.ch <- chunk:
+		return nil
+	case <-b.failed:
+		return b.err
+	}
+}
+
+func (b *streamBuffer) close() error {
+	close(b.ch)
+	<-b.done
+	return b.err
+}
+
+// UTF8SafeCallback wraps a callback so that a multi-byte rune split across
+// two chunks is held back until it is complete. Anything still pending is
+// flushed just before the Done chunk.
+func UTF8SafeCallback(next StreamCallback) StreamCallback {
+	var pending string
+	return func(chunk StreamChunk) error {
+		if err := forwardSideband(&chunk, next); err != nil {
+			return err
+		}
+		data := pending + chunk.Content
+		pending = ""
+		if !chunk.Done {
+			cut := incompleteRuneStart(data)
+			pending = data[cut:]
+			data = data[:cut]
+			if data == "" {
+				return nil
+			}
+			chunk.Content = data
+			return next(chunk)
+		}
+		if data != "" {
+			if err := next(StreamChunk{Content: data, Model: chunk.Model}); err != nil {
+				return err
+			}
+		}
+		chunk.Content = ""
+		return next(chunk)
 	}
+}
+
+// incompleteRuneStart returns the index where a trailing, not yet complete
+// UTF-8 sequence begins, or len(s) when s ends on a rune boundary.
+func incompleteRuneStart(s string) int {
+	for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(s[i]) {
+			continue
+		}
+		if utf8.FullRuneInString(s[i:]) {
+			return len(s)
+		}
+		return i
+	}
+	return len(s)
+}
 
-	return "", nil
+// StreamByLine wraps a callback so that it receives whole lines (including
+// the trailing newline) instead of raw token chunks. The last unterminated
+// line is delivered just before the Done chunk.
+func StreamByLine(next StreamCallback) StreamCallback {
+	return segmentCallback(next, lineEnd)
+}
+
+// StreamBySentence is like StreamByLine but splits after sentence-ending
+// punctuation followed by whitespace, or at a newline. The whitespace stays
+// with the preceding sentence so the pieces concatenate to the original text.
+// A period after a common abbreviation ("Dr.", "e.g.") or a single-letter
+// initial does not end a sentence.
+func StreamBySentence(next StreamCallback) StreamCallback {
+	return segmentCallback(next, sentenceEnd)
+}
+
+// segmentCallback buffers chunk content and emits every complete unit that
+// end reports; end returns the length of the first unit in s or -1.
+func segmentCallback(next StreamCallback, end func(s string) int) StreamCallback {
+	var pending, model string
+	return func(chunk StreamChunk) error {
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if err := forwardSideband(&chunk, next); err != nil {
+			return err
+		}
+		if chunk.Done {
+			if pending != "" {
+				out := pending
+				pending = ""
+				if err := next(StreamChunk{Content: out, Model: model}); err != nil {
+					return err
+				}
+			}
+			return next(chunk)
+		}
+
+		pending += chunk.Content
+		for {
+			n := end(pending)
+			if n <= 0 {
+				return nil
+			}
+			out := pending[:n]
+			pending = pending[n:]
+			if err := next(StreamChunk{Content: out, Model: model}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func lineEnd(s string) int {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return i + 1
+	}
+	return -1
+}
+
+var sentenceAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "vs": true, "e.g": true, "i.e": true, "fig": true,
+}
+
+// isAbbreviation reports whether the word at the end of s, which is followed
+// by a period, is a known abbreviation or a single-letter initial.
+func isAbbreviation(s string) bool {
+	word := s
+	if i := strings.LastIndexFunc(s, unicode.IsSpace); i >= 0 {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		word = s[i+size:]
+	}
+	word = strings.TrimLeft(word, "\"'([«“")
+	if utf8.RuneCountInString(word) == 1 {
+		r, _ := utf8.DecodeRuneInString(word)
+		return unicode.IsLetter(r)
+	}
+	return sentenceAbbreviations[strings.ToLower(word)]
+}
+
+func sentenceEnd(s string) int {
+	for i, r := range s {
+		if r == '\n' {
+			return i + 1
+		}
+		if !strings.ContainsRune(".!?…", r) {
+			continue
+		}
+		if r == '.' && isAbbreviation(s[:i]) {
+			continue
+		}
+		// Skip the rest of "?!", "..." and closing quotes or brackets.
+		j := i + utf8.RuneLen(r)
+		for j < len(s) {
+			next, size := utf8.DecodeRuneInString(s[j:])
+			if !strings.ContainsRune(".!?…\"')]»”", next) {
+				break
+			}
+			j += size
+		}
+		if j == len(s) {
+			// The unit may continue ("3.14", "..."); wait for more text.
+			return -1
+		}
+		next, _ := utf8.DecodeRuneInString(s[j:])
+		if !unicode.IsSpace(next) {
+			continue
+		}
+		for j < len(s) {
+			next, size := utf8.DecodeRuneInString(s[j:])
+			if !unicode.IsSpace(next) {
+				return j
+			}
+			j += size
+		}
+		return -1
+	}
+	return -1
 }
@@ -0,0 +1,631 @@
+package llmclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientSideStopTruncatesStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []string
+		stop   []string
+		want   string
+	}{
+		{"stop inside one chunk", []string{"Hello ", "world STOP and more", " tail"}, []string{"STOP"}, "Hello world "},
+		{"stop split across chunks", []string{"Hello wor", "ld ST", "OP and more"}, []string{"STOP"}, "Hello world "},
+		{"earliest of several stops", []string{"one\n\ntwo END three"}, []string{"END", "\n\n"}, "one"},
+		{"no stop in output", []string{"Hello ", "world"}, []string{"STOP"}, "Hello world"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make([]string, len(tt.deltas))
+			for i, d := range tt.deltas {
+				events[i] = deltaEvent(d)
+			}
+			srv := sseServer(t, events...)
+
+			req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}
+			WithStop(tt.stop...)(req)
+			WithClientSideStop()(req)
+			chunks, resp, err := collectStream(t, NewClient(), req)
+			if err != nil {
+				t.Fatalf("SendStream: %v", err)
+			}
+
+			var got string
+			done := 0
+			for _, chunk := range chunks {
+				got += chunk.Content
+				if chunk.Done {
+					done++
+				}
+			}
+			if got != tt.want || resp.Content != tt.want {
+				t.Errorf("content = %q, response = %q, want %q", got, resp.Content, tt.want)
+			}
+			if done != 1 || !chunks[len(chunks)-1].Done {
+				t.Errorf("got %d Done chunks (last Done=%v), want exactly one at the end", done, chunks[len(chunks)-1].Done)
+			}
+		})
+	}
+}
+
+func TestStreamLongLine(t *testing.T) {
+	big := strings.Repeat("x", 256<<10)
+	ndjson, _ := json.Marshal(map[string]interface{}{"message": map[string]string{"role": "assistant", "content": big}, "done": false})
+
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"sse", "/v1/chat/completions", "data: " + deltaEvent(big) + "\n\ndata: [DONE]\n\n"},
+		{"ollama ndjson", "/api/chat", string(ndjson) + "\n" + `{"message":{"role":"assistant","content":""},"done":true}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			req := &Request{Provider: srv.URL + tt.path, Model: "m", Prompt: "hi"}
+			chunks, resp, err := collectStream(t, NewClient(), req)
+			if err != nil {
+				t.Fatalf("SendStream: %v", err)
+			}
+			var got strings.Builder
+			for _, chunk := range chunks {
+				got.WriteString(chunk.Content)
+			}
+			if got.Len() != len(big) || resp.Content != big {
+				t.Errorf("delivered %d bytes, response %d bytes, want %d", got.Len(), len(resp.Content), len(big))
+			}
+		})
+	}
+}
+
+func TestStreamChannelCancelMidStream(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			io.WriteString(w, "data: "+deltaEvent("tok")+"\n\n")
+		}
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chunks, errs := NewClient().StreamChannel(ctx, &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+
+	select {
+	case chunk := <-chunks:
+		if chunk.Content != "tok" {
+			t.Fatalf("first chunk = %+v", chunk)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no chunk before cancel")
+	}
+	cancel()
+
+	// The remaining chunks are deliberately not read: the goroutine must
+	// still exit, which closes errs after reporting the cancellation.
+	var got []error
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case err, ok := <-errs:
+			if !ok {
+				done = true
+				break
+			}
+			got = append(got, err)
+		case <-timeout:
+			t.Fatal("StreamChannel goroutine did not exit after cancel")
+		}
+	}
+	if len(got) != 1 || !errors.Is(got[0], context.Canceled) {
+		t.Errorf("errors = %v, want [context.Canceled]", got)
+	}
+	for range chunks {
+	}
+}
+
+func TestStartStreamCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: "+deltaEvent("tok")+"\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	first := make(chan struct{})
+	var once sync.Once
+	cancel, done := NewClient().StartStream(context.Background(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}, func(chunk StreamChunk) error {
+		once.Do(func() { close(first) })
+		return nil
+	})
+
+	select {
+	case <-first:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no chunk before cancel")
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("done = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not stop after cancel")
+	}
+	if _, ok := <-done; ok {
+		t.Error("done was not closed after the result")
+	}
+}
+
+// roleEvent is a chat.completion.chunk whose delta carries role and content.
+func roleEvent(role, content string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"role": role, "content": content}}},
+	})
+	return string(data)
+}
+
+func TestStreamRoleMarksMessageStart(t *testing.T) {
+	type piece struct{ role, content string }
+	tests := []struct {
+		name   string
+		events []string
+		wrap   func(StreamCallback) StreamCallback
+		want   []piece
+	}{
+		{
+			"role-only first chunk",
+			[]string{roleEvent("assistant", ""), deltaEvent("Hel"), deltaEvent("lo")},
+			nil,
+			[]piece{{"assistant", ""}, {"", "Hel"}, {"", "lo"}, {"", ""}},
+		},
+		{
+			"role repeated on every delta",
+			[]string{roleEvent("assistant", "Hel"), roleEvent("assistant", "lo"), roleEvent("assistant", "!")},
+			nil,
+			[]piece{{"assistant", "Hel"}, {"", "lo"}, {"", "!"}, {"", ""}},
+		},
+		{
+			"role change starts a new message",
+			[]string{roleEvent("assistant", "a"), roleEvent("assistant", "b"), roleEvent("tool", "c")},
+			nil,
+			[]piece{{"assistant", "a"}, {"", "b"}, {"tool", "c"}, {"", ""}},
+		},
+		{
+			// Only the real message start is split off as a sideband chunk.
+			"repeated role through UTF8SafeCallback",
+			[]string{roleEvent("assistant", "Hel"), roleEvent("assistant", "lo"), roleEvent("assistant", "!")},
+			UTF8SafeCallback,
+			[]piece{{"assistant", ""}, {"", "Hel"}, {"", "lo"}, {"", "!"}, {"", ""}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := sseServer(t, tt.events...)
+			var got []piece
+			var callback StreamCallback = func(chunk StreamChunk) error {
+				got = append(got, piece{chunk.Role, chunk.Content})
+				return nil
+			}
+			if tt.wrap != nil {
+				callback = tt.wrap(callback)
+			}
+			req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}
+			if _, err := NewClient().SendStream(context.Background(), req, callback); err != nil {
+				t.Fatalf("SendStream: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamBufferReadsAhead(t *testing.T) {
+	gate := make(chan struct{})
+	var got []string
+	b := newStreamBuffer(3, func(chunk StreamChunk) error {
+		<-gate
+		got = append(got, chunk.Content)
+		return nil
+	})
+
+	// One chunk is held by the blocked callback and three sit in the
+	// buffer, so none of these pushes may wait for the callback.
+	pushed := make(chan struct{})
+	go func() {
+		for _, s := range []string{"a", "b", "c", "d"} {
+			b.push(StreamChunk{Content: s})
+		}
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("push blocked on a slow callback")
+	}
+
+	close(gate)
+	if err := b.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+}
+
+func TestStreamBufferCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	b := newStreamBuffer(2, func(chunk StreamChunk) error { return stop })
+	b.push(StreamChunk{Content: "a"})
+	// The failure is reported on a later push once the callback has run.
+	deadline := time.After(time.Second)
+	for b.push(StreamChunk{Content: "b"}) == nil {
+		select {
+		case <-deadline:
+			t.Fatal("push never reported the callback error")
+		default:
+		}
+	}
+	if err := b.close(); !errors.Is(err, stop) {
+		t.Errorf("close = %v, want %v", err, stop)
+	}
+}
+
+func TestSendStreamBuffered(t *testing.T) {
+	words := []string{"one ", "two ", "three ", "four ", "five"}
+	var events []string
+	for _, w := range words {
+		events = append(events, deltaEvent(w))
+	}
+	srv := sseServer(t, events...)
+
+	for _, size := range []int{0, 2} {
+		req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "count"}
+		WithStreamBuffer(size)(req)
+		var got []string
+		resp, err := NewClient().SendStream(context.Background(), req, func(chunk StreamChunk) error {
+			time.Sleep(5 * time.Millisecond)
+			if chunk.Content != "" {
+				got = append(got, chunk.Content)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("buffer %d: SendStream: %v", size, err)
+		}
+		if !reflect.DeepEqual(got, words) {
+			t.Errorf("buffer %d: chunks = %q, want %q", size, got, words)
+		}
+		if want := strings.Join(words, ""); resp.Content != want {
+			t.Errorf("buffer %d: content = %q, want %q", size, resp.Content, want)
+		}
+	}
+}
+
+func TestUTF8SafeCallback(t *testing.T) {
+	emoji := "\U0001F600" // four bytes: F0 9F 98 80
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string
+	}{
+		{"emoji split in half", []string{"Hi " + emoji[:2], emoji[2:] + "!"}, []string{"Hi ", emoji + "!"}},
+		{"emoji split three ways", []string{emoji[:1], emoji[1:3], emoji[3:]}, []string{emoji}},
+		{"whole runes pass through", []string{"héllo", " wörld"}, []string{"héllo", " wörld"}},
+		{"incomplete tail flushed at done", []string{"end" + emoji[:3]}, []string{"end", emoji[:3]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			done := false
+			cb := UTF8SafeCallback(func(chunk StreamChunk) error {
+				if done {
+					t.Errorf("chunk %q after done", chunk.Content)
+				}
+				if chunk.Done {
+					done = true
+					return nil
+				}
+				got = append(got, chunk.Content)
+				return nil
+			})
+			for _, c := range tt.chunks {
+				if err := cb(StreamChunk{Content: c}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := cb(StreamChunk{Done: true}); err != nil {
+				t.Fatal(err)
+			}
+			if !done {
+				t.Error("done chunk not forwarded")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamBySentence(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string
+	}{
+		{"split across chunks", []string{"Hel", "lo there. How a", "re you? Fine"},
+			[]string{"Hello there. ", "How are you? ", "Fine"}},
+		{"abbreviations", []string{"Dr. Smith met Mrs. Jones, e.g. at St. Paul. ", "Then J. R. Tolkien left."},
+			[]string{"Dr. Smith met Mrs. Jones, e.g. at St. Paul. ", "Then J. R. Tolkien left."}},
+		{"decimal waits for more text", []string{"Pi is 3.", "14. Yes"},
+			[]string{"Pi is 3.14. ", "Yes"}},
+		{"ellipsis and quotes", []string{"Wait... \"Really?!\" ", "Yes."},
+			[]string{"Wait... ", "\"Really?!\" ", "Yes."}},
+		{"newline ends a unit", []string{"- one\n- two\n- thr", "ee"},
+			[]string{"- one\n", "- two\n", "- three"}},
+		{"trailing fragment flushed at done", []string{"No punctuation here"},
+			[]string{"No punctuation here"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			done := false
+			cb := StreamBySentence(func(chunk StreamChunk) error {
+				if chunk.Done {
+					done = true
+					return nil
+				}
+				got = append(got, chunk.Content)
+				return nil
+			})
+			for _, c := range tt.chunks {
+				if err := cb(StreamChunk{Content: c}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := cb(StreamChunk{Done: true}); err != nil {
+				t.Fatal(err)
+			}
+			if !done {
+				t.Error("done chunk not forwarded")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sentences = %q, want %q", got, tt.want)
+			}
+			if strings.Join(got, "") != strings.Join(tt.chunks, "") {
+				t.Errorf("pieces do not concatenate to the input")
+			}
+		})
+	}
+}
+
+func TestStreamBySentenceFlushAtEOF(t *testing.T) {
+	srv := sseServer(t, deltaEvent("First one. Sec"), deltaEvent("ond one. And a tail"))
+	var got []string
+	_, err := NewClient().SendStream(context.Background(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"}, StreamBySentence(func(chunk StreamChunk) error {
+		if chunk.Content != "" {
+			got = append(got, chunk.Content)
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("SendStream: %v", err)
+	}
+	if want := []string{"First one. ", "Second one. ", "And a tail"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sentences = %q, want %q", got, want)
+	}
+}
+
+func TestNativeStreamUsage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/chat" {
+			io.WriteString(w, `{"model":"llama3","message":{"role":"assistant","content":"Hi"},"done":false}`+"\n")
+			io.WriteString(w, `{"model":"llama3","message":{"role":"assistant","content":" there"},"done":false}`+"\n")
+			io.WriteString(w, `{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":26,"eval_count":4}`+"\n")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range []string{
+			`{"type":"message_start","message":{"model":"claude-3-5-haiku","usage":{"input_tokens":25,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}`,
+			`{"type":"message_stop"}`,
+		} {
+			io.WriteString(w, "data: "+e+"\n\n")
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		provider string
+		endpoint string
+		want     TokenUsage
+	}{
+		{"anthropic", "anthropic", srv.URL + "/v1/messages", TokenUsage{PromptTokens: 25, CompletionTokens: 15, TotalTokens: 40}},
+		{"ollama native", "ollama", srv.URL + "/api/chat", TokenUsage{PromptTokens: 26, CompletionTokens: 4, TotalTokens: 30}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, resp, err := collectStream(t, NewClient(), &Request{Provider: tt.provider, Endpoint: tt.endpoint, Model: "m", Prompt: "hi"})
+			if err != nil {
+				t.Fatalf("SendStream: %v", err)
+			}
+			if resp.Content != "Hi there" {
+				t.Errorf("content = %q", resp.Content)
+			}
+			if resp.Usage == nil || *resp.Usage != tt.want {
+				t.Errorf("usage = %+v, want %+v", resp.Usage, tt.want)
+			}
+			last := chunks[len(chunks)-1]
+			if !last.Done || last.Usage == nil || *last.Usage != tt.want {
+				t.Errorf("done chunk = %+v", last)
+			}
+		})
+	}
+}
+
+// Run with -race: the accumulator is fed and read from several goroutines.
+func TestStreamAccumulatorConcurrent(t *testing.T) {
+	var acc StreamAccumulator
+	const writers, chunks = 8, 200
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = acc.String()
+				_ = acc.Result()
+			}
+		}
+	}()
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < chunks; i++ {
+				acc.Add(StreamChunk{Content: "ab", Model: "m"})
+			}
+		}()
+	}
+	wg.Wait()
+	acc.Add(StreamChunk{Done: true, Content: "ignored", Usage: &TokenUsage{TotalTokens: 7}})
+	close(stop)
+	<-readerDone
+
+	res := acc.Result()
+	// Each Add writes "ab" under the lock, so no chunk is torn.
+	if res.Content != strings.Repeat("ab", writers*chunks) {
+		t.Errorf("content has %d bytes, want %d whole chunks", len(res.Content), writers*chunks)
+	}
+	if res.Model != "m" || res.Usage == nil || res.Usage.TotalTokens != 7 {
+		t.Errorf("model = %q, usage = %+v", res.Model, res.Usage)
+	}
+	if acc.String() != res.Content {
+		t.Error("String() differs from Result().Content")
+	}
+}
+
+func TestMaxStreamLineSize(t *testing.T) {
+	big := strings.Repeat("x", 256<<10)
+	ndjson, _ := json.Marshal(map[string]interface{}{"message": map[string]string{"role": "assistant", "content": big}, "done": false})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/chat" {
+			io.WriteString(w, string(ndjson)+"\n"+`{"message":{"role":"assistant","content":""},"done":true}`+"\n")
+			return
+		}
+		io.WriteString(w, "data: "+deltaEvent(big)+"\n\ndata: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{"below the line", 64 << 10, true},
+		{"above the line", 512 << 10, false},
+		{"default", 0, false},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{"/v1/chat/completions", "/api/chat"} {
+			c := NewClient(WithMaxStreamLineSize(tt.size))
+			_, resp, err := collectStream(t, c, &Request{Provider: srv.URL + path, Model: "m", Prompt: "hi"})
+			if tt.wantErr {
+				if !errors.Is(err, bufio.ErrTooLong) {
+					t.Errorf("%s %s: err = %v, want bufio.ErrTooLong", tt.name, path, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s %s: %v", tt.name, path, err)
+			}
+			if len(resp.Content) != len(big) {
+				t.Errorf("%s %s: got %d bytes, want %d", tt.name, path, len(resp.Content), len(big))
+			}
+		}
+	}
+}
+
+func TestStreamUsageTerminalChunk(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   *TokenUsage
+	}{
+		{"paid after empty choices", []string{
+			deltaEvent("ok"),
+			`{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+		}, &TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}},
+		{"free on the last content chunk", []string{
+			`{"choices":[{"delta":{"content":"ok"}}],"usage":{"input_tokens":7,"output_tokens":3}}`,
+		}, &TokenUsage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10}},
+		{"free zeros", []string{
+			`{"choices":[{"delta":{"content":"ok"}}],"usage":{"input_tokens":0,"output_tokens":0}}`,
+		}, nil},
+		{"free null", []string{
+			`{"choices":[{"delta":{"content":"ok"}}],"usage":null}`,
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := sseServer(t, tt.events...)
+			chunks, resp, err := collectStream(t, NewClient(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi", StreamUsage: true})
+			if err != nil {
+				t.Fatalf("SendStream: %v", err)
+			}
+			if resp.Content != "ok" {
+				t.Errorf("content = %q, want ok", resp.Content)
+			}
+			if !reflect.DeepEqual(resp.Usage, tt.want) {
+				t.Errorf("usage = %+v, want %+v", resp.Usage, tt.want)
+			}
+			last := chunks[len(chunks)-1]
+			if !last.Done || !reflect.DeepEqual(last.Usage, tt.want) {
+				t.Errorf("last chunk = %+v, want Done with usage %+v", last, tt.want)
+			}
+		})
+	}
+}
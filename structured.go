@@ -0,0 +1,189 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidJSON = errors.New("response is not valid JSON for schema")
+
+type JSONSchema struct {
+	Name   string
+	Schema map[string]any
+}
+
+type responseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema *jsonSchemaSpec `json:"json_schema,omitempty"`
+}
+
+type jsonSchemaSpec struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+	Strict bool           `json:"strict"`
+}
+
+func WithStrictJSON(name string, schema map[string]any) SendOption {
+	return func(r *Request) { r.JSONSchema = &JSONSchema{Name: name, Schema: schema} }
+}
+
+func (o chatOptions) responseFormat() *responseFormat {
+	if o.jsonSchema == nil {
+		return nil
+	}
+	if !o.jsonSchemaSupported {
+		return &responseFormat{Type: "json_object"}
+	}
+	name := o.jsonSchema.Name
+	if name == "" {
+		name = "response"
+	}
+	return &responseFormat{
+		Type:       "json_schema",
+		JSONSchema: &jsonSchemaSpec{Name: name, Schema: o.jsonSchema.Schema, Strict: true},
+	}
+}
+
+// appendSchemaInstruction spells the schema out in the system prompt for
+// providers that only understand json_object mode.
+func appendSchemaInstruction(systemPrompt string, schema *JSONSchema) string {
+	data, err := json.Marshal(schema.Schema)
+	if err != nil {
+		return systemPrompt
+	}
+	instruction := "Respond only with a JSON object matching this JSON schema:\n" + string(data)
+	if systemPrompt == "" {
+		return instruction
+	}
+	return systemPrompt + "\n\n" + instruction
+}
+
+// validateJSONResponse checks resp against req.JSONSchema and asks the model
+// to correct itself once before giving up with ErrInvalidJSON.
+func (c *Client) validateJSONResponse(ctx context.Context, req *Request, history []Message, resp *Response) (*Response, error) {
+	err := validateJSONContent(resp.Content, req.JSONSchema.Schema)
+	if err == nil {
+		return resp, nil
+	}
+
+	retryHistory := append(append([]Message(nil), history...),
+		NewAssistantMessage(resp.Content),
+		NewUserMessage(fmt.Sprintf("Your previous reply was not valid JSON for the required schema (%v). Reply again with only the corrected JSON.", err)),
+	)
+	retry, sendErr := c.send(ctx, req, retryHistory)
+	if sendErr != nil {
+		return nil, sendErr
+	}
+	if err := validateJSONContent(retry.Content, req.JSONSchema.Schema); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+	return retry, nil
+}
+
+var jsonFenceRe = regexp.MustCompile("(?s)^```(?:json)?\\s*(.*?)\\s*```$")
+
+func validateJSONContent(content string, schema map[string]any) error {
+	content = strings.TrimSpace(content)
+	if m := jsonFenceRe.FindStringSubmatch(content); len(m) > 1 {
+		content = m[1]
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return fmt.Errorf("parse: %w", err)
+	}
+	return validateJSONValue(value, schema, "$")
+}
+
+// validateJSONValue implements the subset of JSON Schema used for structured
+// output: type, properties, required, items and enum.
+func validateJSONValue(value interface{}, schema map[string]any, path string) error {
+	if schema == nil {
+		return nil
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value not in enum", path)
+		}
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, ok := obj[name]; !ok {
+					return fmt.Errorf("%s: missing required field %q", path, name)
+				}
+			}
+		}
+		if required, ok := schema["required"].([]string); ok {
+			for _, name := range required {
+				if _, ok := obj[name]; !ok {
+					return fmt.Errorf("%s: missing required field %q", path, name)
+				}
+			}
+		}
+		if props, ok := schema["properties"].(map[string]any); ok {
+			for name, propSchema := range props {
+				v, present := obj[name]
+				if !present {
+					continue
+				}
+				ps, _ := propSchema.(map[string]any)
+				if err := validateJSONValue(v, ps, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, v := range arr {
+				if err := validateJSONValue(v, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number", path)
+		}
+	case "integer":
+		f, ok := value.(float64)
+		if !ok || f != float64(int64(f)) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	case "null":
+		if value != nil {
+			return fmt.Errorf("%s: expected null", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStrictJSONSchema(t *testing.T) {
+	var payload map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		payload = decodeBody(t, body)
+		io.WriteString(w, `{"choices":[{"message":{"content":"{\"name\":\"Ann\"}"}}]}`)
+	}))
+	defer srv.Close()
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"name": map[string]any{"type": "string"}},
+		"required":   []any{"name"},
+	}
+	tests := []struct {
+		name         string
+		provider     string
+		wantFormat   map[string]interface{}
+		wantInSystem bool
+	}{
+		{"strict schema", "openai", map[string]interface{}{
+			"type":        "json_schema",
+			"json_schema": map[string]interface{}{"name": "person", "schema": schema, "strict": true},
+		}, false},
+		{"prompt fallback", "groq", map[string]interface{}{"type": "json_object"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{Provider: tt.provider, Endpoint: srv.URL + "/v1/chat/completions", Model: "m", SystemPrompt: "Extract the person.", Prompt: "Ann called."}
+			WithStrictJSON("person", schema)(req)
+			resp, err := NewClient().Send(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if resp.Content != `{"name":"Ann"}` {
+				t.Errorf("content = %q", resp.Content)
+			}
+			if got := payload["response_format"]; !reflect.DeepEqual(got, tt.wantFormat) {
+				t.Errorf("response_format = %v, want %v", got, tt.wantFormat)
+			}
+			system, _ := sentMessages(payload)[0]["content"].(string)
+			if !strings.HasPrefix(system, "Extract the person.") {
+				t.Errorf("system prompt = %q", system)
+			}
+			if got := strings.Contains(system, `"required":["name"]`); got != tt.wantInSystem {
+				t.Errorf("schema in system prompt = %v, want %v: %q", got, tt.wantInSystem, system)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package llmclient
+
+import "strings"
+
+var defaultNoSystemPromptModels = []string{"o1-mini", "o1-preview"}
+
+// WithNoSystemPrompt lists models that reject system messages. For these the
+// system prompt is folded into the first user message. Without arguments the
+// known o1 variants are used.
+func WithNoSystemPrompt(models ...string) ClientOption {
+	return func(c *Client) {
+		if len(models) == 0 {
+			models = defaultNoSystemPromptModels
+		}
+		c.noSystemPrompt = make(map[string]bool, len(models))
+		for _, m := range models {
+			c.noSystemPrompt[strings.ToLower(m)] = true
+		}
+	}
+}
+
+func (c *Client) rejectsSystemPrompt(model string) bool {
+	if len(c.noSystemPrompt) == 0 {
+		return false
+	}
+	model = strings.ToLower(model)
+	if c.noSystemPrompt[model] {
+		return true
+	}
+	// OpenRouter-style IDs carry a vendor prefix, e.g. "openai/o1-mini".
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		return c.noSystemPrompt[model[i+1:]]
+	}
+	return false
+}
+
+// foldSystemPrompt removes system messages and prepends their text, together
+// with systemPrompt, to the first user message.
+func foldSystemPrompt(systemPrompt string, history []Message) []Message {
+	var system []string
+	if systemPrompt != "" {
+		system = append(system, systemPrompt)
+	}
+	msgs := make([]Message, 0, len(history))
+	for _, m := range history {
+		if m.Role == "system" {
+			system = append(system, messageText(m))
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	if len(system) == 0 {
+		return history
+	}
+
+	return prependToFirstUser(strings.Join(system, "\n\n"), msgs)
+}
+
+// prependToFirstUser puts prefix in front of the first user message, or in a
+// new user message at the start when there is none.
+func prependToFirstUser(prefix string, msgs []Message) []Message {
+	for i, m := range msgs {
+		if m.Role != "user" {
+			continue
+		}
+		if len(m.ContentParts) > 0 {
+			m.ContentParts = append([]ContentPart{NewTextPart(prefix)}, m.ContentParts...)
+		}
+		if m.Content != "" {
+			m.Content = prefix + "\n\n" + m.Content
+		} else {
+			m.Content = prefix
+		}
+		msgs[i] = m
+		return msgs
+	}
+	return append([]Message{NewUserMessage(prefix)}, msgs...)
+}
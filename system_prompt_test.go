@@ -0,0 +1,75 @@
+package llmclient
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestFoldSystemPrompt(t *testing.T) {
+	image := NewImageURLPart("https://example.com/a.png")
+	tests := []struct {
+		name         string
+		systemPrompt string
+		history      []Message
+		want         []Message
+	}{
+		{
+			"system prompt into first user turn",
+			"Be brief.",
+			[]Message{NewUserMessage("hi"), NewAssistantMessage("hello"), NewUserMessage("bye")},
+			[]Message{NewUserMessage("Be brief.\n\nhi"), NewAssistantMessage("hello"), NewUserMessage("bye")},
+		},
+		{
+			"system messages are removed and joined",
+			"Be brief.",
+			[]Message{NewSystemMessage("Answer in French."), NewAssistantMessage("Bonjour"), NewUserMessage("hi")},
+			[]Message{NewAssistantMessage("Bonjour"), NewUserMessage("Be brief.\n\nAnswer in French.\n\nhi")},
+		},
+		{
+			"content parts get a leading text part",
+			"Describe it.",
+			[]Message{NewMessageWithContentParts("user", []ContentPart{image})},
+			[]Message{{Role: "user", Content: "Describe it.", ContentParts: []ContentPart{NewTextPart("Describe it."), image}}},
+		},
+		{
+			"no user turn adds one",
+			"Be brief.",
+			[]Message{NewAssistantMessage("hello")},
+			[]Message{NewUserMessage("Be brief."), NewAssistantMessage("hello")},
+		},
+		{
+			"nothing to fold",
+			"",
+			[]Message{NewUserMessage("hi")},
+			[]Message{NewUserMessage("hi")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := foldSystemPrompt(tt.systemPrompt, tt.history); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoSystemPromptModel(t *testing.T) {
+	srv, lastPayload := samplingServer(t)
+	c := NewClient(WithNoSystemPrompt())
+	for _, tt := range []struct {
+		model string
+		want  []string
+	}{
+		{"openai/o1-mini", []string{"user:Be brief.\n\nhi"}},
+		{"gpt-4o", []string{"system:Be brief.", "user:hi"}},
+	} {
+		req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: tt.model, SystemPrompt: "Be brief.", Prompt: "hi"}
+		if _, err := c.Send(context.Background(), req); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if got := roles(sentMessages(lastPayload())); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: messages = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package llmclient
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+type TokenEstimator func(text string) int
+
+// EstimateTokens is a rough, tokenizer-free estimate of ~4 characters per token.
+func EstimateTokens(text string) int {
+	n := utf8.RuneCountInString(text)
+	return (n + 3) / 4
+}
+
+// EstimateImageTokens applies OpenAI's vision pricing: a flat 85 tokens for
+// "low" detail; otherwise the image is fit into 2048x2048, its short side is
+// scaled down to 768 and every 512px tile costs 170 tokens on top of the 85.
+// "auto" and "" count as "high".
+func EstimateImageTokens(width, height int, detail string) int {
+	const base, perTile = 85, 170
+	if strings.EqualFold(detail, "low") {
+		return base
+	}
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+
+	w, h := float64(width), float64(height)
+	if longest := math.Max(w, h); longest > 2048 {
+		w, h = w*2048/longest, h*2048/longest
+	}
+	if shortest := math.Min(w, h); shortest > 768 {
+		w, h = w*768/shortest, h*768/shortest
+	}
+	tiles := int(math.Ceil(w/512)) * int(math.Ceil(h/512))
+	return base + perTile*tiles
+}
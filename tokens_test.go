@@ -0,0 +1,29 @@
+package llmclient
+
+import "testing"
+
+func TestEstimateImageTokens(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		detail        string
+		want          int
+	}{
+		{"1024x1024 high", 1024, 1024, "high", 765},
+		{"1024x1024 auto", 1024, 1024, "auto", 765},
+		{"1024x1024 default detail", 1024, 1024, "", 765},
+		{"1024x1024 low", 1024, 1024, "low", 85},
+		{"low ignores size", 4096, 4096, "LOW", 85},
+		{"2048x4096 fit then scaled", 2048, 4096, "high", 1105},
+		{"512x512 single tile", 512, 512, "high", 255},
+		{"small image single tile", 100, 50, "high", 255},
+		{"unknown size", 0, 1024, "high", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateImageTokens(tt.width, tt.height, tt.detail); got != tt.want {
+				t.Errorf("EstimateImageTokens(%d, %d, %q) = %d, want %d", tt.width, tt.height, tt.detail, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package llmclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	TranscriptText  = "text"
+	TranscriptJSONL = "jsonl"
+)
+
+var transcriptRoles = map[string]bool{"system": true, "user": true, "assistant": true, "tool": true}
+
+// ParseMessages reads a conversation from r. TranscriptText expects
+// "role: content" lines, where lines without a role prefix continue the
+// previous message; TranscriptJSONL expects one {"role","content"} object per
+// line. Blank lines are ignored in both formats.
+func ParseMessages(r io.Reader, format string) ([]Message, error) {
+	switch strings.ToLower(format) {
+	case TranscriptText:
+		return parseTextTranscript(r)
+	case TranscriptJSONL:
+		return parseJSONLTranscript(r)
+	default:
+		return nil, fmt.Errorf("unknown transcript format: %s", format)
+	}
+}
+
+func parseTextTranscript(r io.Reader) ([]Message, error) {
+	var messages []Message
+	scanner := newTranscriptScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if role, content, ok := strings.Cut(line, ":"); ok && transcriptRoles[strings.ToLower(strings.TrimSpace(role))] {
+			messages = append(messages, Message{Role: strings.ToLower(strings.TrimSpace(role)), Content: strings.TrimSpace(content)})
+			continue
+		}
+		if len(messages) == 0 {
+			return nil, fmt.Errorf("line %d: expected \"role: content\"", n)
+		}
+		last := &messages[len(messages)-1]
+		last.Content += "\n" + line
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+	return messages, nil
+}
+
+func parseJSONLTranscript(r io.Reader) ([]Message, error) {
+	var messages []Message
+	scanner := newTranscriptScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var m struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if !transcriptRoles[m.Role] {
+			return nil, fmt.Errorf("line %d: invalid role %q", n, m.Role)
+		}
+		messages = append(messages, Message{Role: m.Role, Content: m.Content})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+	return messages, nil
+}
+
+func newTranscriptScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), defaultMaxStreamLineSize)
+	return scanner
+}
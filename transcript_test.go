@@ -0,0 +1,54 @@
+package llmclient
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMessages(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		input   string
+		want    []Message
+		wantErr string
+	}{
+		{"text", TranscriptText,
+			"System: Be brief.\n\nuser: Write a haiku\nassistant: Autumn moonlight:\n  a worm digs silently\ninto the chestnut.\nUser: Thanks: great\n",
+			[]Message{
+				NewSystemMessage("Be brief."),
+				NewUserMessage("Write a haiku"),
+				NewAssistantMessage("Autumn moonlight:\n  a worm digs silently\ninto the chestnut."),
+				NewUserMessage("Thanks: great"),
+			}, ""},
+		{"text continuation before any role", TranscriptText, "hello there\nuser: hi\n", nil, "line 1"},
+		{"text unknown role is continuation", TranscriptText, "user: note\nbot: hi\n",
+			[]Message{NewUserMessage("note\nbot: hi")}, ""},
+		{"jsonl", "JSONL",
+			`{"role":"system","content":"Be brief."}` + "\n\n" +
+				`{"role":"user","content":"line one\nline two"}` + "\n" +
+				`  {"role":"assistant","content":"ok"}  ` + "\n",
+			[]Message{NewSystemMessage("Be brief."), NewUserMessage("line one\nline two"), NewAssistantMessage("ok")}, ""},
+		{"jsonl malformed line", TranscriptJSONL, `{"role":"user","content":"hi"}` + "\n" + `{"role":"assistant",` + "\n", nil, "line 2"},
+		{"jsonl invalid role", TranscriptJSONL, `{"role":"user","content":"hi"}` + "\n\n" + `{"role":"bot","content":"x"}` + "\n", nil, `line 3: invalid role "bot"`},
+		{"unknown format", "csv", "user,hi", nil, "unknown transcript format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMessages(strings.NewReader(tt.input), tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMessages: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package llmclient
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,27 +14,48 @@ import (
 	"strings"
 )
 
+// TranscriptionRequest carries the audio in FileData or, for large files,
+// FileReader, which is streamed into the multipart form and wins when both are
+// set. FileName names the form file and hints the format either way.
 type TranscriptionRequest struct {
 	Provider       string
 	Model          string
 	APIKey         string
 	FileName       string
 	FileData       []byte
+	FileReader     io.Reader
 	Language       string
 	Prompt         string
 	ResponseFormat string
 	Temperature    *float64
+	Base64         bool
+	Diarize        bool
 }
 
 type TranscriptionResponse struct {
-	Text string
-	Raw  []byte
+	Text     string
+	Segments []TranscriptionSegment
+	Headers  map[string]string
+	Raw      []byte
+}
+
+type TranscriptionSegment struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Text    string  `json:"text"`
+	Speaker string  `json:"speaker,omitempty"`
 }
 
 func (c *Client) TranscribeAudio(ctx context.Context, req *TranscriptionRequest) (*TranscriptionResponse, error) {
 	if req == nil {
 		return nil, errors.New("transcription request is nil")
 	}
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	provider, err := c.newTranscriptionProvider(req)
 	if err != nil {
@@ -45,7 +67,8 @@ func (c *Client) TranscribeAudio(ctx context.Context, req *TranscriptionRequest)
 		return nil, err
 	}
 
-	return &TranscriptionResponse{Text: text, Raw: raw}, nil
+	c.observeResponseBytes(req.Provider, len(raw))
+	return &TranscriptionResponse{Text: text, Segments: extractTranscriptionSegments(raw), Headers: captured.headers(), Raw: raw}, nil
 }
 
 func (c *Client) newTranscriptionProvider(req *TranscriptionRequest) (transcriptionProvider, error) {
@@ -68,15 +91,98 @@ type pollinationsTranscriptionProvider struct {
 }
 
 func (p *pollinationsTranscriptionProvider) Transcribe(ctx context.Context, req *TranscriptionRequest) (string, []byte, error) {
+	httpReq, err := newTranscriptionRequest(ctx, "https://gen.pollinations.ai/v1/audio/transcriptions", req, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", nil, fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		return "", nil, &APIError{StatusCode: resp.StatusCode, Body: string(respData), Provider: req.Provider}
+	}
+	if err := checkContentType(resp, respData); err != nil {
+		return "", nil, err
+	}
+
+	text := extractTranscriptionText(respData)
+	return text, respData, nil
+}
+
+func newTranscriptionRequest(ctx context.Context, url string, req *TranscriptionRequest, stream bool) (*http.Request, error) {
+	var (
+		body        io.Reader
+		contentType string
+		err         error
+	)
+	switch {
+	case req.Base64:
+		body, contentType, err = transcriptionJSONBody(req, stream)
+	case req.FileReader != nil:
+		body, contentType = transcriptionMultipartStream(req, stream)
+	default:
+		body, contentType, err = transcriptionMultipartBody(req, stream)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", contentType)
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	if req.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
+	}
+	return httpReq, nil
+}
+
+func transcriptionMultipartBody(req *TranscriptionRequest, stream bool) (*bytes.Buffer, string, error) {
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
+	if err := writeTranscriptionForm(writer, req, stream); err != nil {
+		return nil, "", err
+	}
+	return &body, writer.FormDataContentType(), nil
+}
+
+// transcriptionMultipartStream writes the form through a pipe so the audio
+// from FileReader is never held in memory as a whole.
+func transcriptionMultipartStream(req *TranscriptionRequest, stream bool) (io.Reader, string) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeTranscriptionForm(writer, req, stream))
+	}()
+	return pr, writer.FormDataContentType()
+}
 
+func writeTranscriptionForm(writer *multipart.Writer, req *TranscriptionRequest, stream bool) error {
 	fileWriter, err := writer.CreateFormFile("file", filepath.Base(req.FileName))
 	if err != nil {
-		return "", nil, fmt.Errorf("create form file: %w", err)
+		return fmt.Errorf("create form file: %w", err)
+	}
+	if req.FileReader != nil {
+		_, err = io.Copy(fileWriter, req.FileReader)
+	} else {
+		_, err = fileWriter.Write(req.FileData)
 	}
-	if _, err := fileWriter.Write(req.FileData); err != nil {
-		return "", nil, fmt.Errorf("write file data: %w", err)
+	if err != nil {
+		return fmt.Errorf("write file data: %w", err)
 	}
 
 	if req.Model != "" {
@@ -94,38 +200,72 @@ func (p *pollinationsTranscriptionProvider) Transcribe(ctx context.Context, req
 	if req.Temperature != nil {
 		_ = writer.WriteField("temperature", fmt.Sprintf("%.2f", *req.Temperature))
 	}
+	if req.Diarize {
+		_ = writer.WriteField("diarize", "true")
+	}
+	if stream {
+		_ = writer.WriteField("stream", "true")
+	}
 
 	if err := writer.Close(); err != nil {
-		return "", nil, fmt.Errorf("close multipart writer: %w", err)
+		return fmt.Errorf("close multipart writer: %w", err)
 	}
+	return nil
+}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://gen.pollinations.ai/v1/audio/transcriptions", &body)
+// transcriptionJSONBody is used for gateways that strip multipart bodies and
+// accept the audio base64-encoded in a JSON payload instead.
+func transcriptionJSONBody(req *TranscriptionRequest, stream bool) (*bytes.Buffer, string, error) {
+	fileData, err := transcriptionFileData(req)
 	if err != nil {
-		return "", nil, fmt.Errorf("create request: %w", err)
+		return nil, "", err
 	}
-
-	httpReq.Header.Set("Content-Type", writer.FormDataContentType())
-	if req.APIKey != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
+	payload := map[string]interface{}{
+		"file": base64.StdEncoding.EncodeToString(fileData),
 	}
-
-	resp, err := p.client.Do(httpReq)
-	if err != nil {
-		return "", nil, fmt.Errorf("request: %w", err)
+	if req.FileName != "" {
+		payload["filename"] = filepath.Base(req.FileName)
+	}
+	if req.Model != "" {
+		payload["model"] = req.Model
+	}
+	if req.Language != "" {
+		payload["language"] = req.Language
+	}
+	if req.Prompt != "" {
+		payload["prompt"] = req.Prompt
+	}
+	if req.ResponseFormat != "" {
+		payload["response_format"] = req.ResponseFormat
+	}
+	if req.Temperature != nil {
+		payload["temperature"] = *req.Temperature
+	}
+	if req.Diarize {
+		payload["diarize"] = true
+	}
+	if stream {
+		payload["stream"] = true
 	}
-	defer resp.Body.Close()
 
-	respData, err := io.ReadAll(resp.Body)
+	data, err := json.Marshal(payload)
 	if err != nil {
-		return "", nil, fmt.Errorf("read response: %w", err)
+		return nil, "", fmt.Errorf("marshal: %w", err)
 	}
+	return bytes.NewBuffer(data), "application/json", nil
+}
 
-	if resp.StatusCode >= 300 {
-		return "", nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(respData))
+// transcriptionFileData reads FileReader for the paths that need the whole
+// file at once.
+func transcriptionFileData(req *TranscriptionRequest) ([]byte, error) {
+	if req.FileReader == nil {
+		return req.FileData, nil
 	}
-
-	text := extractTranscriptionText(respData)
-	return text, respData, nil
+	data, err := io.ReadAll(req.FileReader)
+	if err != nil {
+		return nil, fmt.Errorf("read file data: %w", err)
+	}
+	return data, nil
 }
 
 func extractTranscriptionText(data []byte) string {
@@ -138,3 +278,15 @@ func extractTranscriptionText(data []byte) string {
 	}
 	return string(data)
 }
+
+// extractTranscriptionSegments reads verbose/diarized JSON output; Speaker is
+// only filled when the backend ran diarization.
+func extractTranscriptionSegments(data []byte) []TranscriptionSegment {
+	var result struct {
+		Segments []TranscriptionSegment `json:"segments"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	return result.Segments
+}
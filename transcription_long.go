@@ -0,0 +1,168 @@
+package llmclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// longTranscriptionConcurrency caps the chunk requests in flight so an hour
+// of audio does not open hundreds of connections at once.
+const longTranscriptionConcurrency = 4
+
+var ErrUnsupportedAudioFormat = errors.New("unsupported audio format")
+
+// TranscribeLong splits PCM WAV audio into chunks of chunkDuration,
+// transcribes them concurrently and stitches the text in order. Segment
+// timestamps are shifted by the chunk offset. Other formats have to be split
+// by the caller. Cancelling ctx stops the chunks that are still running.
+func (c *Client) TranscribeLong(ctx context.Context, req *TranscriptionRequest, chunkDuration time.Duration) (*TranscriptionResponse, error) {
+	if req == nil {
+		return nil, errors.New("transcription request is nil")
+	}
+	data, err := transcriptionFileData(req)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := splitWAV(data, chunkDuration)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 1 {
+		wholeReq := *req
+		wholeReq.FileData, wholeReq.FileReader = data, nil
+		return c.TranscribeAudio(ctx, &wholeReq)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*TranscriptionResponse, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, longTranscriptionConcurrency)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk []byte) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			chunkReq := *req
+			chunkReq.FileData, chunkReq.FileReader = chunk, nil
+			results[i], errs[i] = c.TranscribeAudio(ctx, &chunkReq)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, fmt.Errorf("transcribe chunk %d: %w", i, err)
+		}
+	}
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("transcribe chunk %d: %w", i, err)
+		}
+	}
+	return stitchTranscriptions(results, chunkDuration), nil
+}
+
+func stitchTranscriptions(results []*TranscriptionResponse, chunkDuration time.Duration) *TranscriptionResponse {
+	out := &TranscriptionResponse{}
+	texts := make([]string, 0, len(results))
+	for i, r := range results {
+		if text := strings.TrimSpace(r.Text); text != "" {
+			texts = append(texts, text)
+		}
+		offset := float64(i) * chunkDuration.Seconds()
+		for _, seg := range r.Segments {
+			seg.Start += offset
+			seg.End += offset
+			out.Segments = append(out.Segments, seg)
+		}
+		if out.Headers == nil {
+			out.Headers = r.Headers
+		}
+	}
+	out.Text = strings.Join(texts, " ")
+	return out
+}
+
+// splitWAV cuts the data chunk of a RIFF/WAVE file on frame boundaries and
+// gives every piece its own canonical 44-byte header.
+func splitWAV(data []byte, chunkDuration time.Duration) ([][]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("%w: long transcription needs WAV audio", ErrUnsupportedAudioFormat)
+	}
+	if chunkDuration <= 0 {
+		return [][]byte{data}, nil
+	}
+
+	var fmtChunk, pcm []byte
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		start := pos + 8
+		end := start + size
+		if end > len(data) {
+			end = len(data)
+		}
+		switch id {
+		case "fmt ":
+			fmtChunk = data[start:end]
+		case "data":
+			pcm = data[start:end]
+		}
+		pos = start + size + size%2
+	}
+	if len(fmtChunk) < 16 || pcm == nil {
+		return nil, fmt.Errorf("%w: malformed WAV header", ErrUnsupportedAudioFormat)
+	}
+
+	byteRate := int64(binary.LittleEndian.Uint32(fmtChunk[8:12]))
+	blockAlign := int64(binary.LittleEndian.Uint16(fmtChunk[12:14]))
+	if byteRate == 0 || blockAlign == 0 {
+		return nil, fmt.Errorf("%w: malformed WAV header", ErrUnsupportedAudioFormat)
+	}
+	chunkBytes := byteRate * int64(chunkDuration) / int64(time.Second)
+	chunkBytes -= chunkBytes % blockAlign
+	if chunkBytes <= 0 || int64(len(pcm)) <= chunkBytes {
+		return [][]byte{data}, nil
+	}
+
+	var chunks [][]byte
+	for off := int64(0); off < int64(len(pcm)); off += chunkBytes {
+		end := off + chunkBytes
+		if end > int64(len(pcm)) {
+			end = int64(len(pcm))
+		}
+		chunks = append(chunks, wavFile(fmtChunk[:16], pcm[off:end]))
+	}
+	return chunks, nil
+}
+
+func wavFile(format, pcm []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	_ = binary.Write(&b, binary.LittleEndian, uint32(36+len(pcm)))
+	b.WriteString("WAVEfmt ")
+	_ = binary.Write(&b, binary.LittleEndian, uint32(len(format)))
+	b.Write(format)
+	b.WriteString("data")
+	_ = binary.Write(&b, binary.LittleEndian, uint32(len(pcm)))
+	b.Write(pcm)
+	return b.Bytes()
+}
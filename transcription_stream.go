@@ -0,0 +1,138 @@
+package llmclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// TranscriptionChunk is a piece of a streamed transcription. Text holds only
+// the new part; the last chunk has Done set and no text.
+type TranscriptionChunk struct {
+	Text string
+	Done bool
+}
+
+type transcriptionStreamer interface {
+	TranscribeStream(ctx context.Context, req *TranscriptionRequest, callback func(TranscriptionChunk) error) error
+}
+
+// TranscribeAudioStream sends the audio with stream=true and delivers the
+// partial text as the provider produces it. A provider that ignores the flag
+// and answers with plain JSON yields the whole text in one chunk.
+func (c *Client) TranscribeAudioStream(ctx context.Context, req *TranscriptionRequest, callback func(TranscriptionChunk) error) error {
+	if req == nil {
+		return errors.New("transcription request is nil")
+	}
+	if callback == nil {
+		return errors.New("transcription callback is nil")
+	}
+	ctx, received := c.startByteCount(ctx)
+	ctx = c.withStreamLineSize(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	provider, err := c.newTranscriptionProvider(req)
+	if err != nil {
+		return err
+	}
+	streamer, ok := provider.(transcriptionStreamer)
+	if !ok {
+		return fmt.Errorf("transcription provider %s does not support streaming", req.Provider)
+	}
+	if err := streamer.TranscribeStream(ctx, req, callback); err != nil {
+		return err
+	}
+	c.observeResponseBytes(req.Provider, received.count())
+	return nil
+}
+
+func (p *pollinationsTranscriptionProvider) TranscribeStream(ctx context.Context, req *TranscriptionRequest, callback func(TranscriptionChunk) error) error {
+	httpReq, err := newTranscriptionRequest(ctx, "https://gen.pollinations.ai/v1/audio/transcriptions", req, true)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respData, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Body: string(respData), Provider: req.Provider}
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		respData, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("read response: %w", err)
+		}
+		if err := checkContentType(resp, respData); err != nil {
+			return err
+		}
+		if err := callback(TranscriptionChunk{Text: extractTranscriptionText(respData)}); err != nil {
+			return err
+		}
+		return callback(TranscriptionChunk{Done: true})
+	}
+	return parseTranscriptionStream(ctx, resp.Body, callback)
+}
+
+// parseTranscriptionStream reads OpenAI-style transcript.text.delta and
+// transcript.text.done events; bare {"text": ...} deltas are accepted too.
+func parseTranscriptionStream(ctx context.Context, reader io.Reader, callback func(TranscriptionChunk) error) error {
+	scanner := newStreamScanner(ctx, reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return callback(TranscriptionChunk{Done: true})
+		}
+
+		var event struct {
+			Type  string `json:"type"`
+			Delta string `json:"delta"`
+			Text  string `json:"text"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+		if event.Error != nil {
+			return errors.New(event.Error.Message)
+		}
+
+		var text string
+		switch event.Type {
+		case "transcript.text.done":
+			return callback(TranscriptionChunk{Done: true})
+		case "transcript.text.delta":
+			text = event.Delta
+		case "":
+			text = event.Text
+		}
+		if text == "" {
+			continue
+		}
+		if err := callback(TranscriptionChunk{Text: text}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return callback(TranscriptionChunk{Done: true})
+}
@@ -0,0 +1,279 @@
+package llmclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTranscribeAudioStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []TranscriptionChunk
+		wantErr     bool
+	}{
+		{
+			"transcript deltas",
+			"text/event-stream",
+			"data: {\"type\":\"transcript.text.delta\",\"delta\":\"Hello\"}\n\n" +
+				"data: {\"type\":\"transcript.text.delta\",\"delta\":\" world\"}\n\n" +
+				"data: {\"type\":\"transcript.text.done\",\"text\":\"Hello world\"}\n\n",
+			[]TranscriptionChunk{{Text: "Hello"}, {Text: " world"}, {Done: true}},
+			false,
+		},
+		{
+			"bare text deltas",
+			"text/event-stream",
+			"data: {\"text\":\"Hello\"}\n\ndata: {\"text\":\" world\"}\n\ndata: [DONE]\n\n",
+			[]TranscriptionChunk{{Text: "Hello"}, {Text: " world"}, {Done: true}},
+			false,
+		},
+		{
+			"stream ends without done event",
+			"text/event-stream",
+			"data: {\"type\":\"transcript.text.delta\",\"delta\":\"Hello\"}\n\n",
+			[]TranscriptionChunk{{Text: "Hello"}, {Done: true}},
+			false,
+		},
+		{
+			"error event",
+			"text/event-stream",
+			"data: {\"type\":\"transcript.text.delta\",\"delta\":\"Hel\"}\n\ndata: {\"error\":{\"message\":\"audio too long\"}}\n\n",
+			[]TranscriptionChunk{{Text: "Hel"}},
+			true,
+		},
+		{
+			"plain JSON fallback",
+			"application/json",
+			`{"text":"Hello world"}`,
+			[]TranscriptionChunk{{Text: "Hello world"}, {Done: true}},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stream string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				stream = r.FormValue("stream")
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			var got []TranscriptionChunk
+			c := NewClient(WithHTTPClient(rewriteClient(srv)))
+			req := &TranscriptionRequest{Provider: "pollinations", FileName: "a.wav", FileData: []byte("RIFF")}
+			err := c.TranscribeAudioStream(context.Background(), req, func(chunk TranscriptionChunk) error {
+				got = append(got, chunk)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stream != "true" {
+				t.Errorf("stream form field = %q, want true", stream)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranscribeAudioBase64(t *testing.T) {
+	audio := []byte("RIFF\x00\x01fake wav data")
+	tests := []struct {
+		name   string
+		reader bool
+	}{
+		{"file data", false},
+		{"file reader", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				contentType string
+				payload     map[string]interface{}
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+				body, _ := io.ReadAll(r.Body)
+				payload = decodeBody(t, body)
+				w.Header().Set("Content-Type", "application/json")
+				io.WriteString(w, `{"text":"hello"}`)
+			}))
+			defer srv.Close()
+
+			req := &TranscriptionRequest{Provider: "pollinations", Model: "whisper", FileName: "/tmp/a.wav", Language: "en", Base64: true}
+			if tt.reader {
+				req.FileReader = bytes.NewReader(audio)
+			} else {
+				req.FileData = audio
+			}
+			resp, err := NewClient(WithHTTPClient(rewriteClient(srv))).TranscribeAudio(context.Background(), req)
+			if err != nil {
+				t.Fatalf("TranscribeAudio: %v", err)
+			}
+			if resp.Text != "hello" {
+				t.Errorf("text = %q", resp.Text)
+			}
+			if contentType != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", contentType)
+			}
+			want := map[string]interface{}{
+				"file":     base64.StdEncoding.EncodeToString(audio),
+				"filename": "a.wav",
+				"model":    "whisper",
+				"language": "en",
+			}
+			if !reflect.DeepEqual(payload, want) {
+				t.Errorf("payload = %v, want %v", payload, want)
+			}
+		})
+	}
+}
+
+func TestTranscribeAudioFileReader(t *testing.T) {
+	audio := append([]byte("RIFF"), bytes.Repeat([]byte("pcm "), 256<<10)...)
+	var (
+		got             []byte
+		filename, model string
+		contentLength   int64
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("FormFile: %v", err)
+			return
+		}
+		got, _ = io.ReadAll(file)
+		filename, model = header.Filename, r.FormValue("model")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"text":"hello"}`)
+	}))
+	defer srv.Close()
+
+	// FileReader wins over FileData.
+	req := &TranscriptionRequest{Provider: "pollinations", Model: "whisper", FileName: "/tmp/long.wav", FileReader: bytes.NewReader(audio), FileData: []byte("ignored")}
+	resp, err := NewClient(WithHTTPClient(rewriteClient(srv))).TranscribeAudio(context.Background(), req)
+	if err != nil {
+		t.Fatalf("TranscribeAudio: %v", err)
+	}
+	if resp.Text != "hello" {
+		t.Errorf("text = %q", resp.Text)
+	}
+	if !bytes.Equal(got, audio) {
+		t.Errorf("uploaded %d bytes, want the reader's %d", len(got), len(audio))
+	}
+	if filename != "long.wav" || model != "whisper" {
+		t.Errorf("filename = %q, model = %q", filename, model)
+	}
+	if contentLength != -1 {
+		t.Errorf("Content-Length = %d, want a streamed (chunked) body", contentLength)
+	}
+}
+
+const diarizedTranscription = `{
+	"task": "transcribe",
+	"language": "english",
+	"duration": 6.2,
+	"text": "Shall we start? Yes, let's go.",
+	"segments": [
+		{"id": 0, "start": 0.0, "end": 1.4, "text": "Shall we start?", "speaker": "SPEAKER_00"},
+		{"id": 1, "start": 1.9, "end": 3.1, "text": "Yes, let's go.", "speaker": "SPEAKER_01"}
+	]
+}`
+
+func TestTranscribeAudioDiarize(t *testing.T) {
+	for _, base64Body := range []bool{false, true} {
+		var diarize interface{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if base64Body {
+				body, _ := io.ReadAll(r.Body)
+				diarize = decodeBody(t, body)["diarize"]
+			} else {
+				diarize = r.FormValue("diarize")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, diarizedTranscription)
+		}))
+
+		req := &TranscriptionRequest{Provider: "pollinations", FileName: "meeting.wav", FileData: []byte("RIFF"), ResponseFormat: "verbose_json", Diarize: true, Base64: base64Body}
+		resp, err := NewClient(WithHTTPClient(rewriteClient(srv))).TranscribeAudio(context.Background(), req)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("base64 %v: TranscribeAudio: %v", base64Body, err)
+		}
+		// A form field in multipart uploads, a JSON boolean in base64 bodies.
+		var wantDiarize interface{} = "true"
+		if base64Body {
+			wantDiarize = true
+		}
+		if diarize != wantDiarize {
+			t.Errorf("base64 %v: diarize = %v, want %v", base64Body, diarize, wantDiarize)
+		}
+		if resp.Text != "Shall we start? Yes, let's go." {
+			t.Errorf("text = %q", resp.Text)
+		}
+		want := []TranscriptionSegment{
+			{Start: 0, End: 1.4, Text: "Shall we start?", Speaker: "SPEAKER_00"},
+			{Start: 1.9, End: 3.1, Text: "Yes, let's go.", Speaker: "SPEAKER_01"},
+		}
+		if !reflect.DeepEqual(resp.Segments, want) {
+			t.Errorf("segments = %+v, want %+v", resp.Segments, want)
+		}
+	}
+}
+
+func TestTranscribeLong(t *testing.T) {
+	// 8-bit mono at 100 Hz: one second is 100 bytes, so 200 bytes of PCM
+	// make two 1s chunks. Each chunk is filled with its own index so the
+	// server can tell them apart.
+	format := []byte{1, 0, 1, 0, 100, 0, 0, 0, 100, 0, 0, 0, 1, 0, 8, 0}
+	pcm := append(bytes.Repeat([]byte{0}, 100), bytes.Repeat([]byte{1}, 100)...)
+	replies := []string{
+		`{"text":" First half. ","segments":[{"start":0.2,"end":0.9,"text":"First half."}]}`,
+		`{"text":"Second half.","segments":[{"start":0.1,"end":0.8,"text":"Second half."}]}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("FormFile: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		chunk, err := splitWAV(data, 0)
+		if err != nil || len(data) != 44+100 {
+			t.Errorf("chunk is not a 1s WAV: %d bytes, %v", len(data), err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, replies[chunk[0][44]])
+	}))
+	defer srv.Close()
+
+	req := &TranscriptionRequest{Provider: "pollinations", FileName: "long.wav", FileData: wavFile(format, pcm), ResponseFormat: "verbose_json"}
+	resp, err := NewClient(WithHTTPClient(rewriteClient(srv))).TranscribeLong(context.Background(), req, time.Second)
+	if err != nil {
+		t.Fatalf("TranscribeLong: %v", err)
+	}
+	if resp.Text != "First half. Second half." {
+		t.Errorf("text = %q", resp.Text)
+	}
+	want := []TranscriptionSegment{
+		{Start: 0.2, End: 0.9, Text: "First half."},
+		{Start: 1.1, End: 1.8, Text: "Second half."},
+	}
+	if !reflect.DeepEqual(resp.Segments, want) {
+		t.Errorf("segments = %+v, want %+v", resp.Segments, want)
+	}
+}
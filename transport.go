@@ -0,0 +1,76 @@
+package llmclient
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var ErrRequestTooLarge = errors.New("request too large")
+
+func WithForceHTTP1() ClientOption {
+	return func(c *Client) { c.forceHTTP1 = true }
+}
+
+// forceHTTP1 returns a copy of hc whose transport never negotiates HTTP/2.
+// A non-nil empty TLSNextProto map is what disables h2 over TLS; "h2" is also
+// dropped from a custom TLS config's ALPN list, or the server would pick it.
+func forceHTTP1(hc *http.Client) *http.Client {
+	var t *http.Transport
+	switch rt := hc.Transport.(type) {
+	case nil:
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		t = rt.Clone()
+	default:
+		return hc
+	}
+	t.ForceAttemptHTTP2 = false
+	t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	if t.TLSClientConfig != nil {
+		var protos []string
+		for _, p := range t.TLSClientConfig.NextProtos {
+			if p != "h2" {
+				protos = append(protos, p)
+			}
+		}
+		t.TLSClientConfig.NextProtos = protos
+	}
+
+	copied := *hc
+	copied.Transport = t
+	return &copied
+}
+
+// WithMaxRequestBytes rejects any request whose body is larger than n bytes
+// before it is sent, so an oversized prompt or base64 image fails with
+// ErrRequestTooLarge instead of a provider's opaque 413.
+func WithMaxRequestBytes(n int64) ClientOption {
+	return func(c *Client) { c.maxRequestBytes = n }
+}
+
+type requestLimitTransport struct {
+	base  http.RoundTripper
+	limit int64
+}
+
+func (t *requestLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.ContentLength > t.limit {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrRequestTooLarge, req.ContentLength, t.limit)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func wrapTransport(hc *http.Client, wrap func(http.RoundTripper) http.RoundTripper) *http.Client {
+	base := hc.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	copied := *hc
+	copied.Transport = wrap(base)
+	return &copied
+}
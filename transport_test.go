@@ -0,0 +1,78 @@
+package llmclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForceHTTP1TransportConfig(t *testing.T) {
+	custom := &http.Transport{ForceAttemptHTTP2: true}
+	opaque := roundTripFunc(func(*http.Request) (*http.Response, error) { return nil, nil })
+
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		wantForce bool
+	}{
+		{"default transport", nil, true},
+		{"custom transport", custom, true},
+		{"opaque round tripper", opaque, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient(WithHTTPClient(&http.Client{Transport: tt.transport}), WithForceHTTP1())
+			tr, ok := c.httpClient.Transport.(*http.Transport)
+			if !tt.wantForce {
+				if ok {
+					t.Fatalf("transport replaced with %T, want the caller's round tripper", c.httpClient.Transport)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("transport = %T, want *http.Transport", c.httpClient.Transport)
+			}
+			if tr.ForceAttemptHTTP2 {
+				t.Error("ForceAttemptHTTP2 = true, want false")
+			}
+			if tr.TLSNextProto == nil || len(tr.TLSNextProto) != 0 {
+				t.Errorf("TLSNextProto = %v, want a non-nil empty map", tr.TLSNextProto)
+			}
+		})
+	}
+	if !custom.ForceAttemptHTTP2 {
+		t.Error("the caller's transport was modified instead of cloned")
+	}
+}
+
+func TestForceHTTP1Negotiation(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"choices":[{"message":{"content":"`+r.Proto+`"}}]}`)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		opts []ClientOption
+		want string
+	}{
+		{"h2 by default", nil, "HTTP/2.0"},
+		{"forced HTTP/1.1", []ClientOption{WithForceHTTP1()}, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]ClientOption{WithHTTPClient(srv.Client())}, tt.opts...)
+			resp, err := NewClient(opts...).Send(context.Background(), &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", Prompt: "hi"})
+			if err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if resp.Content != tt.want {
+				t.Errorf("protocol = %s, want %s", resp.Content, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,13 @@ package llmclient
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -35,11 +37,16 @@ type UsageRecord struct {
 	Raw       map[string]any `json:"-"`
 }
 
+// UsageTotals is filled from a provider's usage endpoint or, through
+// Client.UsageSnapshot, from the responses the client has received. Prompt
+// and completion tokens are only split where the source reports them.
 type UsageTotals struct {
-	TotalRequests int64   `json:"total_requests,omitempty"`
-	TotalTokens   int64   `json:"total_tokens,omitempty"`
-	TotalCost     float64 `json:"total_cost,omitempty"`
-	Currency      string  `json:"currency,omitempty"`
+	TotalRequests    int64   `json:"total_requests,omitempty"`
+	PromptTokens     int64   `json:"prompt_tokens,omitempty"`
+	CompletionTokens int64   `json:"completion_tokens,omitempty"`
+	TotalTokens      int64   `json:"total_tokens,omitempty"`
+	TotalCost        float64 `json:"total_cost,omitempty"`
+	Currency         string  `json:"currency,omitempty"`
 }
 
 type Usage struct {
@@ -48,9 +55,98 @@ type Usage struct {
 	Raw     map[string]any
 }
 
+// ComputeTotals sums tokens and cost over Records (or the parsed CSV export
+// when there are none) for providers that do not send totals. The currency is
+// taken from the first record that has one.
+func (u *Usage) ComputeTotals() *UsageTotals {
+	records := u.Records
+	if len(records) == 0 {
+		records, _ = u.ParseCSV()
+	}
+	totals := &UsageTotals{TotalRequests: int64(len(records))}
+	for _, r := range records {
+		totals.TotalTokens += r.Tokens
+		totals.TotalCost += r.Cost
+		if totals.Currency == "" {
+			totals.Currency = r.Currency
+		}
+	}
+	return totals
+}
+
+// ParseCSV turns the export fetched with UsageFormatCSV into records, matching
+// columns by header name. Every column is also kept in the record's Raw map.
+func (u *Usage) ParseCSV() ([]UsageRecord, error) {
+	data, _ := u.Raw["csv"].(string)
+	if data == "" {
+		return nil, errors.New("usage has no csv data")
+	}
+
+	reader := csv.NewReader(strings.NewReader(data))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse csv: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	header := make([]string, len(rows[0]))
+	for i, name := range rows[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+	}
+	records := make([]UsageRecord, 0, len(rows)-1)
+	for n, row := range rows[1:] {
+		record := UsageRecord{Raw: make(map[string]any, len(row))}
+		for i, value := range row {
+			if i >= len(header) {
+				break
+			}
+			record.Raw[header[i]] = value
+			if err := setUsageField(&record, header[i], strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("parse csv row %d: %w", n+2, err)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func setUsageField(record *UsageRecord, column, value string) error {
+	var err error
+	switch column {
+	case "timestamp", "time", "date", "created_at":
+		record.Timestamp = value
+	case "model":
+		record.Model = value
+	case "provider":
+		record.Provider = value
+	case "type":
+		record.Type = value
+	case "prompt":
+		record.Prompt = value
+	case "tokens", "total_tokens":
+		if value != "" {
+			record.Tokens, err = strconv.ParseInt(value, 10, 64)
+		}
+	case "cost", "price", "amount":
+		if value != "" {
+			record.Cost, err = strconv.ParseFloat(value, 64)
+		}
+	case "currency":
+		record.Currency = value
+	}
+	if err != nil {
+		return fmt.Errorf("column %s: %w", column, err)
+	}
+	return nil
+}
+
 type UsageResponse struct {
-	Usage *Usage
-	Raw   []byte
+	Usage   *Usage
+	Headers map[string]string
+	Raw     []byte
 }
 
 type usageProvider interface {
@@ -69,6 +165,12 @@ func (c *Client) GetUsage(ctx context.Context, req *UsageRequest) (*UsageRespons
 	if req == nil {
 		return nil, errors.New("usage request is nil")
 	}
+	ctx, captured := c.startHeaderCapture(ctx)
+	release, err := c.acquireProvider(ctx, req.Provider)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	if req.Format == "" {
 		req.Format = UsageFormatJSON
 	}
@@ -83,7 +185,11 @@ func (c *Client) GetUsage(ctx context.Context, req *UsageRequest) (*UsageRespons
 		return nil, err
 	}
 
-	return &UsageResponse{Usage: usage, Raw: raw}, nil
+	if usage.Totals == nil {
+		usage.Totals = usage.ComputeTotals()
+	}
+	c.observeResponseBytes(req.Provider, len(raw))
+	return &UsageResponse{Usage: usage, Headers: captured.headers(), Raw: raw}, nil
 }
 
 func (c *Client) newUsageProvider(req *UsageRequest) (usageProvider, error) {
@@ -134,7 +240,10 @@ func (p *pollinationsUsageProvider) GetUsage(ctx context.Context, req *UsageRequ
 		return nil, nil, fmt.Errorf("read response: %w", err)
 	}
 	if resp.StatusCode >= 300 {
-		return nil, nil, fmt.Errorf("api error %d: %s", resp.StatusCode, string(data))
+		return nil, nil, &APIError{StatusCode: resp.StatusCode, Body: string(data), Provider: req.Provider}
+	}
+	if err := checkContentType(resp, data); err != nil {
+		return nil, nil, err
 	}
 
 	if req.Format == UsageFormatCSV {
@@ -0,0 +1,42 @@
+package llmclient
+
+import "sync"
+
+// UsageTracker sums the token usage reported in chat and stream responses.
+// Every client has its own; pass one tracker to several clients with
+// WithUsageTracker to account for them together.
+type UsageTracker struct {
+	mu     sync.Mutex
+	totals UsageTotals
+}
+
+func WithUsageTracker(t *UsageTracker) ClientOption {
+	return func(c *Client) { c.usage = t }
+}
+
+func (t *UsageTracker) record(usage *TokenUsage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.totals.TotalRequests++
+	if usage != nil {
+		t.totals.PromptTokens += usage.PromptTokens
+		t.totals.CompletionTokens += usage.CompletionTokens
+		t.totals.TotalTokens += usage.TotalTokens
+	}
+}
+
+func (t *UsageTracker) Snapshot() UsageTotals {
+	if t == nil {
+		return UsageTotals{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.totals
+}
+
+func (c *Client) UsageSnapshot() UsageTotals {
+	return c.usage.Snapshot()
+}
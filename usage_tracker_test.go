@@ -0,0 +1,58 @@
+package llmclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestUsageSnapshotSumsResponses(t *testing.T) {
+	var (
+		mu sync.Mutex
+		n  int64
+	)
+	// Request n reports n prompt and 10*n completion tokens.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		n++
+		usage := fmt.Sprintf(`{"prompt_tokens":%d,"completion_tokens":%d,"total_tokens":%d}`, n, 10*n, 11*n)
+		mu.Unlock()
+		if r.URL.Path == "/stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: "+deltaEvent("ok")+"\n\n")
+			io.WriteString(w, `data: {"choices":[],"usage":`+usage+"}\n\ndata: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"ok"}}],"usage":`+usage+`}`)
+	}))
+	defer srv.Close()
+
+	tracker := &UsageTracker{}
+	first := NewClient(WithUsageTracker(tracker))
+	second := NewClient(WithUsageTracker(tracker))
+
+	for _, c := range []*Client{first, second, first} {
+		if _, err := c.Send(context.Background(), &Request{Provider: srv.URL + "/chat", Model: "m", Prompt: "hi"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	req := &Request{Provider: srv.URL + "/stream", Model: "m", Prompt: "hi"}
+	WithStreamUsage()(req)
+	if _, _, err := collectStream(t, second, req); err != nil {
+		t.Fatalf("SendStream: %v", err)
+	}
+
+	want := UsageTotals{TotalRequests: 4, PromptTokens: 10, CompletionTokens: 100, TotalTokens: 110}
+	for _, c := range []*Client{first, second} {
+		if got := c.UsageSnapshot(); got != want {
+			t.Errorf("UsageSnapshot() = %+v, want %+v", got, want)
+		}
+	}
+	if got := NewClient().UsageSnapshot(); got != (UsageTotals{}) {
+		t.Errorf("fresh client snapshot = %+v, want zero", got)
+	}
+}
@@ -0,0 +1,58 @@
+package llmclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidMessages = errors.New("invalid message order")
+
+// strictRoleProviders reject two user or two assistant turns in a row, so
+// the alternation check runs for them even without WithMessageValidator.
+var strictRoleProviders = map[string]bool{"anthropic": true, "mistral": true}
+
+// WithMessageValidator checks Request.Messages before anything is sent:
+// system messages only at the start, and no two consecutive user or
+// assistant messages. A violation fails with ErrInvalidMessages naming the
+// index in Request.Messages instead of the provider's 400. Few-shot examples
+// and retrieved context are added after the check.
+func WithMessageValidator() SendOption {
+	return func(r *Request) { r.ValidateMessages = true }
+}
+
+func validateRequestMessages(req *Request) error {
+	if req.ValidateMessages {
+		return validateMessages(req.Messages, true)
+	}
+	if strictRoleProviders[strings.ToLower(strings.TrimSpace(req.Provider))] {
+		return validateMessages(req.Messages, false)
+	}
+	return nil
+}
+
+// validateMessages checks role alternation, skipping system and tool
+// messages; with systemFirst it also requires system messages to come
+// before any other.
+func validateMessages(history []Message, systemFirst bool) error {
+	prev := ""
+	seenOther := false
+	for i, m := range history {
+		switch m.Role {
+		case "system":
+			if systemFirst && seenOther {
+				return fmt.Errorf("%w: message %d: system message after conversation start", ErrInvalidMessages, i)
+			}
+			continue
+		case "tool":
+			prev = m.Role
+		case "user", "assistant":
+			if m.Role == prev {
+				return fmt.Errorf("%w: message %d: two consecutive %s messages", ErrInvalidMessages, i, m.Role)
+			}
+			prev = m.Role
+		}
+		seenOther = true
+	}
+	return nil
+}
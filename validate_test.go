@@ -0,0 +1,87 @@
+package llmclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateMessages(t *testing.T) {
+	sys, user, asst := NewSystemMessage("s"), NewUserMessage("u"), NewAssistantMessage("a")
+	tool := NewToolMessage("call_1", "42")
+	tests := []struct {
+		name        string
+		history     []Message
+		systemFirst bool
+		wantErr     string // empty: valid
+	}{
+		{"empty", nil, true, ""},
+		{"alternating", []Message{sys, user, asst, user}, true, ""},
+		{"several leading system messages", []Message{sys, sys, user}, true, ""},
+		{"tool result between turns", []Message{user, asst, tool, asst, user}, true, ""},
+		{"system mid-conversation", []Message{user, sys, asst}, true, "message 1: system message after conversation start"},
+		{"system mid-conversation allowed", []Message{user, sys, asst}, false, ""},
+		{"two user turns", []Message{sys, user, user}, true, "message 2: two consecutive user messages"},
+		{"two assistant turns", []Message{user, asst, asst}, false, "message 2: two consecutive assistant messages"},
+		{"system does not break a run", []Message{user, sys, user}, false, "message 2: two consecutive user messages"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMessages(tt.history, tt.systemFirst)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidMessages) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want ErrInvalidMessages with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageValidatorChecksCallerMessages(t *testing.T) {
+	srv, _ := samplingServer(t)
+	c := NewClient(WithFewShot([]Message{NewUserMessage("2+2?"), NewAssistantMessage("4")}))
+
+	tests := []struct {
+		name     string
+		messages []Message
+		wantErr  string
+	}{
+		{"few-shot and context", []Message{NewUserMessage("3+3?")}, ""},
+		{"longer session", []Message{NewUserMessage("3+3?"), NewAssistantMessage("6"), NewUserMessage("4+4?")}, ""},
+		{"caller's system message mid-conversation", []Message{NewUserMessage("3+3?"), NewSystemMessage("s")}, "message 1: system message"},
+		{"caller's consecutive turns", []Message{NewUserMessage("3+3?"), NewUserMessage("4+4?")}, "message 1: two consecutive user messages"},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += "/stream"
+			}
+			t.Run(name, func(t *testing.T) {
+				req := &Request{Provider: srv.URL + "/v1/chat/completions", Model: "m", SystemPrompt: "Be brief", Messages: tt.messages}
+				WithRetrievedContext("doc")(req)
+				WithMessageValidator()(req)
+				var err error
+				if stream {
+					_, _, err = collectStream(t, c, req)
+				} else {
+					_, err = c.Send(context.Background(), req)
+				}
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Errorf("request: %v", err)
+					}
+					return
+				}
+				if !errors.Is(err, ErrInvalidMessages) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want ErrInvalidMessages with %q", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}